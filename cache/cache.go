@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	pathpkg "path"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// FileName is the name of the cache file kept in the journal root.
+const FileName = ".devj-cache"
+
+// version changes whenever the cache format does, so old caches are thrown away.
+const version = 3
+
+// File is the cached state of a single entry file.
+type File struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"`
+
+	// Entry holds the public sections of the file, and Tags are the tags in them.
+	// Both are empty for a Sealed file, which is encrypted as a whole.
+	Entry  entry.Entry `json:"entry"`
+	Tags   []string    `json:"tags"`
+	Sealed bool        `json:"sealed,omitempty"`
+	// Full is the whole file, private sections included, if the cache was opened to hold them
+	// and nothing in the file is encrypted.
+	Full *entry.Entry `json:"full,omitempty"`
+
+	// Attachments are the other files in the entry's attachment folder, as of DirModTime.
+	Attachments []string  `json:"attachments"`
+	DirModTime  time.Time `json:"dir_mod_time"`
+}
+
+// Cache holds parsed entries keyed by their path relative to the journal root.
+// It is safe for concurrent use.
+type Cache struct {
+	fsys   filesystem.FS
+	public map[string]struct{}
+	// full is set if the cache holds whole files, as well as their public sections.
+	full  bool
+	mu    sync.Mutex
+	files map[string]*File
+	seen  map[string]struct{}
+	dirty bool
+}
+
+type cacheFile struct {
+	Version int `json:"version"`
+	// Public are the public sections the entries were cached with.
+	Public []string `json:"public"`
+	// Full is set if the files were cached whole.
+	Full  bool             `json:"full"`
+	Files map[string]*File `json:"files"`
+}
+
+// Open reads the cache of the journal in fsys, which keeps the sections in pubSections.
+// If full is set, whole files are cached too, private sections included, which must only be asked for
+// when those are already in plain text in the journal.
+// A missing, unreadable or outdated cache is not an error; it just starts out empty.
+// So does a cache made with other public sections, or another full.
+func Open(fsys filesystem.FS, pubSections map[string]struct{}, full bool) *Cache {
+	c := &Cache{fsys: fsys, public: pubSections, full: full, files: map[string]*File{}, seen: map[string]struct{}{}}
+	bts, err := fsys.ReadFile(FileName)
+	if err != nil {
+		return c
+	}
+	var cf cacheFile
+	if err := json.Unmarshal(bts, &cf); err != nil || cf.Version != version || cf.Files == nil ||
+		!reflect.DeepEqual(cf.Public, sortedKeys(pubSections)) || cf.Full != full {
+		c.dirty = true
+		return c
+	}
+	for _, f := range cf.Files {
+		// Match the times the FS returns.
+		f.ModTime, f.DirModTime = f.ModTime.Local(), f.DirModTime.Local()
+	}
+	c.files = cf.Files
+	return c
+}
+
+// Lookup returns the parsed entry file at path, with the attachments found in dir,
+// both names in the journal's FS. dir may be missing, or be the folder of the entry file.
+// The cached copy is used as long as the file's size and modification time, or failing those its hash,
+// are unchanged. Otherwise the file is parsed again and the cache is updated.
+func (c *Cache) Lookup(path, dir string) (*File, error) {
+	info, err := c.fsys.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var dirModTime time.Time
+	dirInfo, err := c.fsys.Stat(dir)
+	if err == nil {
+		dirModTime = dirInfo.ModTime()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.seen[path] = struct{}{}
+	cached := c.files[path]
+	c.mu.Unlock()
+
+	f := &File{Path: path}
+	if cached != nil {
+		*f = *cached
+	}
+	changed := false
+
+	if cached == nil || cached.Size != info.Size() || !cached.ModTime.Equal(info.ModTime()) {
+		changed = true
+		bts, err := c.fsys.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f.Size, f.ModTime = info.Size(), info.ModTime()
+		if hash := Hash(bts); cached == nil || hash != cached.Hash {
+			e, err := entry.ImportPublic(string(bts), c.public)
+			sealed := errors.Is(err, entry.ErrSealed)
+			if err != nil && !sealed {
+				return nil, err
+			}
+			f.Hash, f.Entry, f.Tags, f.Sealed, f.Full = hash, e, e.Tags(), sealed, nil
+			if c.full && !sealed {
+				full, err := entry.Import(string(bts))
+				if err != nil {
+					return nil, err
+				}
+				if !hasSealed(full) {
+					f.Full = &full
+				}
+			}
+		}
+	}
+
+	if cached == nil || !cached.DirModTime.Equal(dirModTime) {
+		changed = true
+		f.Attachments = nil
+		if !dirModTime.IsZero() {
+			names, err := filesystem.ListFiles(c.fsys, dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range names {
+				if pathpkg.Join(dir, name) != pathpkg.Clean(path) {
+					f.Attachments = append(f.Attachments, name)
+				}
+			}
+		}
+		f.DirModTime = dirModTime
+	}
+
+	if changed {
+		c.mu.Lock()
+		c.files[path] = f
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return f, nil
+}
+
+// Prune drops every cached file that has not been looked up since the cache was opened,
+// such as entries that have been deleted.
+func (c *Cache) Prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path := range c.files {
+		if _, ok := c.seen[path]; !ok {
+			delete(c.files, path)
+			c.dirty = true
+		}
+	}
+}
+
+// Reset empties the cache.
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = map[string]*File{}
+	c.seen = map[string]struct{}{}
+	c.dirty = true
+}
+
+// Save writes the cache back to the journal if it has changed.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	bts, err := json.Marshal(cacheFile{Version: version, Public: sortedKeys(c.public), Full: c.full, Files: c.files})
+	if err != nil {
+		return err
+	}
+	if err := c.fsys.WriteFile(FileName, bts); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// Verify compares every cached file with the one in the journal, hashing each of them,
+// and describes each cached file that is out of date.
+// Entry files missing from the cache are not checked; see Missing.
+func (c *Cache) Verify() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var stale []string
+	for _, path := range c.paths() {
+		f := c.files[path]
+		info, err := c.fsys.Stat(path)
+		if err != nil {
+			stale = append(stale, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		bts, err := c.fsys.ReadFile(path)
+		if err != nil {
+			stale = append(stale, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		switch {
+		case Hash(bts) != f.Hash:
+			stale = append(stale, fmt.Sprintf("%s: contents changed", path))
+		case info.Size() != f.Size || !info.ModTime().Equal(f.ModTime):
+			stale = append(stale, fmt.Sprintf("%s: size or modification time changed", path))
+		}
+	}
+	return stale
+}
+
+// Missing lists the paths that are not cached.
+func (c *Cache) Missing(paths []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var missing []string
+	for _, path := range paths {
+		if _, ok := c.files[path]; !ok {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// Hash is the hex encoded SHA-256 hash of b.
+func Hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// hasSealed reports whether any section of e is encrypted.
+func hasSealed(e entry.Entry) bool {
+	for _, s := range e.Sections {
+		if s.Sealed() {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Cache) paths() []string {
+	var paths []string
+	for path := range c.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
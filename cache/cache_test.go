@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+func writeEntry(t *testing.T, root, date, contents string) string {
+	path := filepath.Join(date, date+".md")
+	if err := os.MkdirAll(filepath.Join(root, date), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, path), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCache(t *testing.T) {
+	root, err := ioutil.TempDir("", "devj-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	path := writeEntry(t, root, "2019-01-01", "# Do\n\n#oncall paged\n\n# Learn\n\nsee a.png\n")
+	if err := ioutil.WriteFile(filepath.Join(root, "2019-01-01", "a.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deleted := writeEntry(t, root, "2019-01-02", "# Do\n")
+
+	public := map[string]struct{}{"do": {}}
+	c := Open(filesystem.OS(root), public, false)
+	f, err := c.Lookup(path, filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the public section is cached.
+	if len(f.Entry.Sections) != 1 || f.Entry.Sections[0].Title != "Do" || !reflect.DeepEqual(f.Tags, []string{"oncall"}) ||
+		!reflect.DeepEqual(f.Attachments, []string{"a.png"}) || f.Full != nil {
+		t.Errorf("unexpected cached file %+v", f)
+	}
+	if _, err := c.Lookup(deleted, filepath.Dir(deleted)); err != nil {
+		t.Fatal(err)
+	}
+	// An entry whose attachment folder does not exist has no attachments.
+	if f, err := c.Lookup(deleted, "2019-01-02/attachments"); err != nil || f.Attachments != nil {
+		t.Errorf("unexpected attachments %+v, %v", f, err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cache made with other public sections is thrown away.
+	if c := Open(filesystem.OS(root), map[string]struct{}{"learn": {}}, false); len(c.files) != 0 {
+		t.Errorf("cache made with other public sections was used")
+	}
+	if c := Open(filesystem.OS(root), public, true); len(c.files) != 0 {
+		t.Errorf("cache made without whole files was used to hold them")
+	}
+
+	// A reopened cache uses the saved copy.
+	c = Open(filesystem.OS(root), public, false)
+	cached, err := c.Lookup(path, filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cached.Entry, f.Entry) || cached.Hash != f.Hash {
+		t.Errorf(`Actual: "%+v" Expected: "%+v"`, cached, f)
+	}
+	if c.dirty {
+		t.Errorf("an unchanged lookup marked the cache as changed")
+	}
+
+	// Changing a file is noticed by Verify, and by the next Lookup.
+	os.RemoveAll(filepath.Join(root, "2019-01-02"))
+	writeEntry(t, root, "2019-01-01", "# Do\n\nchanged\n")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(root, path), later, later)
+
+	stale := c.Verify()
+	expected := []string{"2019-01-01/2019-01-01.md: contents changed"}
+	if len(stale) != 2 || stale[0] != expected[0] {
+		t.Errorf(`Actual: "%v" Expected: "%v" and a missing file`, stale, expected)
+	}
+	if missing := c.Missing([]string{path, "2019-01-03/2019-01-03.md"}); len(missing) != 1 {
+		t.Errorf("got %v missing, expected only 2019-01-03", missing)
+	}
+
+	changed, err := c.Lookup(path, filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Entry.Sections[0].Body != "changed" || changed.Tags != nil {
+		t.Errorf("changed file was not parsed again: %+v", changed)
+	}
+
+	// Prune drops the deleted entry, since it was not looked up.
+	c.Prune()
+	if _, ok := c.files[deleted]; ok {
+		t.Errorf("deleted entry was not pruned")
+	}
+	if stale := c.Verify(); len(stale) != 0 {
+		t.Errorf("cache is still stale: %v", stale)
+	}
+}
+
+func TestCache_Full(t *testing.T) {
+	root, err := ioutil.TempDir("", "devj-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	path := writeEntry(t, root, "2019-01-01", "# Do\n\npaged\n\n# Learn\n\nsee a.png\n")
+	sealed := writeEntry(t, root, "2019-01-02", "-----BEGIN DEVJ ENCRYPTED-----\nabc\n-----END DEVJ ENCRYPTED-----\n")
+
+	c := Open(filesystem.OS(root), map[string]struct{}{"do": {}}, true)
+	f, err := c.Lookup(path, filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Full == nil || len(f.Full.Sections) != 2 || len(f.Entry.Sections) != 1 {
+		t.Errorf("unexpected cached file %+v", f)
+	}
+	if f, err := c.Lookup(sealed, filepath.Dir(sealed)); err != nil || !f.Sealed || f.Full != nil {
+		t.Errorf("unexpected cached file %+v, %v", f, err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	c = Open(filesystem.OS(root), map[string]struct{}{"do": {}}, true)
+	if cached, err := c.Lookup(path, filepath.Dir(path)); err != nil || !reflect.DeepEqual(cached.Full, f.Full) {
+		t.Errorf(`Actual: "%+v" Expected: "%+v"`, cached, f)
+	}
+}
@@ -0,0 +1,108 @@
+// Package crypt encrypts parts of a journal with a key derived from a passphrase.
+// Encrypted text is kept as an armored block of base64 lines, which a markdown entry can hold
+// in place of a section's body, or of the whole entry.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	beginLine = "-----BEGIN DEVJ ENCRYPTED-----"
+	endLine   = "-----END DEVJ ENCRYPTED-----"
+	lineWidth = 64
+)
+
+// The scrypt cost recommended for keys derived while someone waits.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Key encrypts and decrypts text with AES-256-GCM.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// NewSalt returns a random salt for DeriveKey.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// DeriveKey derives the key for passphrase and salt with scrypt.
+func DeriveKey(passphrase string, salt []byte) (*Key, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	b, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(b)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead}, nil
+}
+
+// Seal encrypts plaintext into an armored block. Sealing the same text twice gives different blocks.
+func (k *Key) Seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	// Without padding no line is only "=", which markdown would read as underlining the line above.
+	enc := base64.RawStdEncoding.EncodeToString(k.aead.Seal(nonce, nonce, plaintext, nil))
+
+	lines := []string{beginLine}
+	for len(enc) > lineWidth {
+		lines = append(lines, enc[:lineWidth])
+		enc = enc[lineWidth:]
+	}
+	lines = append(lines, enc, endLine)
+	return strings.Join(lines, "\n"), nil
+}
+
+// Open decrypts an armored block made by Seal.
+func (k *Key) Open(sealed string) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, fmt.Errorf("not encrypted text")
+	}
+	lines := strings.Split(strings.TrimSpace(sealed), "\n")
+	var enc strings.Builder
+	for _, l := range lines[1 : len(lines)-1] {
+		enc.WriteString(strings.TrimSpace(l))
+	}
+	b, err := base64.RawStdEncoding.DecodeString(enc.String())
+	if err != nil || len(b) < k.aead.NonceSize() {
+		return nil, fmt.Errorf("damaged encrypted text")
+	}
+	n := k.aead.NonceSize()
+	plaintext, err := k.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase, or damaged encrypted text")
+	}
+	return plaintext, nil
+}
+
+// IsSealed reports whether s, ignoring the space around it, is a block made by Seal.
+func IsSealed(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, beginLine+"\n") && strings.HasSuffix(s, "\n"+endLine)
+}
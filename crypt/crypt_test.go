@@ -0,0 +1,56 @@
+package crypt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DeriveKey("correct horse", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := DeriveKey("battery staple", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, text := range []string{"", "a", "- met with Sam about the outage\n- #oncall", strings.Repeat("long ", 100)} {
+		sealed, err := key.Seal([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsSealed(sealed) || strings.Contains(sealed, "Sam") {
+			t.Errorf("not sealed: %q", sealed)
+		}
+		for _, line := range strings.Split(sealed, "\n") {
+			if strings.Trim(line, "=") == "" {
+				t.Errorf("sealed text has a line %q markdown reads as underlining", line)
+			}
+		}
+		if again, _ := key.Seal([]byte(text)); again == sealed {
+			t.Errorf("sealing %q twice gave the same block", text)
+		}
+
+		opened, err := key.Open(sealed)
+		if err != nil || string(opened) != text {
+			t.Errorf(`Actual: "%s", %v Expected: "%s"`, opened, err, text)
+		}
+		if _, err := wrong.Open(sealed); err == nil {
+			t.Errorf("opened %q with the wrong key", text)
+		}
+		// Changing any of the text is noticed.
+		damaged := strings.Replace(sealed, "\n", "\nA", 1)
+		if _, err := key.Open(damaged); err == nil {
+			t.Errorf("opened damaged text %q", damaged)
+		}
+	}
+
+	if IsSealed("# Do\n\nthings") {
+		t.Errorf("plain text counts as sealed")
+	}
+}
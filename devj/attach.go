@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// AttachCommand copies a file into the folder of today's entry, and links it from one of its sections.
+func AttachCommand(fs *flag.FlagSet, _ *Config) runFunc {
+	section := fs.String("section", "Learn", "the section to link the file from")
+	name := fs.String("name", "", "the name to give the file, instead of its own")
+	return func(conf *Config, args []string) error {
+		file := args[0]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(workDir, file)
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if *name == "" {
+			*name = filepath.Base(file)
+		}
+
+		unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		today := conf.Today()
+		if !conf.Layout.Exists(conf.FS, today) {
+			return fmt.Errorf("no entry for today to attach to; make one with devj new")
+		}
+		stored, err := conf.attach(today, data, *name, *section)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("attached %s, linked from %s\n", stored, *section)
+		return nil
+	}
+}
+
+// attach writes data to the folder of the entry for date as name, or a name like it if that is taken,
+// and links it from the section titled section. It returns where the file was written.
+// The file is removed again if the entry cannot be written.
+func (c *Config) attach(date entry.Date, data []byte, name, section string) (string, error) {
+	if err := checkAttachmentName(name); err != nil {
+		return "", err
+	}
+	e, raw, err := c.readEntry(date)
+	if err != nil {
+		return "", err
+	}
+
+	dir := c.Layout.Dir(date)
+	if err := filesystem.EnsureFolderExists(c.FS, dir); err != nil {
+		return "", err
+	}
+	stored := path.Join(dir, name)
+	ext := path.Ext(name)
+	for i := 1; exists(c.FS, stored); i++ {
+		stored = path.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
+	}
+	if err := filesystem.SafeWriteFile(c.FS, stored, data); err != nil {
+		return "", err
+	}
+
+	before := e
+	before.Sections = append([]entry.Section(nil), e.Sections...)
+	e.AddLink(section, path.Base(stored))
+	if err := c.writeEntry(date, e, raw); err != nil {
+		// Without the link the file would only be left for gc to find.
+		if rmErr := c.FS.Remove(stored); rmErr != nil {
+			return "", fmt.Errorf("%v; %s was left unlinked, devj gc -remove removes it", err, stored)
+		}
+		return "", err
+	}
+	return stored, c.autoCommit(date, &before, &e, stored)
+}
+
+// AttachmentsCommand lists the attachments of every entry, or renames or removes one of them, as sub says.
+// Attachments are named by their path in the journal, as the list shows them.
+func AttachmentsCommand(sub string) runFunc {
+	return func(conf *Config, args []string) error {
+		if sub == "list" {
+			return listAttachments(conf)
+		}
+
+		unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if sub == "rename" {
+			links, err := conf.renameAttachment(filepath.ToSlash(args[0]), args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("renamed %s to %s, changing %d mentions of it\n", args[0], args[1], links)
+			return nil
+		}
+
+		mentions, err := conf.removeAttachment(filepath.ToSlash(args[0]))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("removed %s\n", args[0])
+		if len(mentions) > 0 {
+			fmt.Printf("it is still mentioned in %s\n", strings.Join(mentions, ", "))
+		}
+		return nil
+	}
+}
+
+// listAttachments prints the attachments of every entry, and the sections linking to them.
+func listAttachments(conf *Config) error {
+	dates, err := conf.Layout.List(conf.FS)
+	if err != nil {
+		return err
+	}
+	for _, date := range dates {
+		names, err := conf.attachments(date)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			continue
+		}
+		e, _, err := conf.readEntry(date)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			linked := "not linked"
+			if titles := e.Mentions(name); len(titles) > 0 {
+				linked = "linked from " + strings.Join(titles, ", ")
+			}
+			fmt.Printf("%s (%s)\n", path.Join(conf.Layout.Dir(date), name), linked)
+		}
+	}
+	return nil
+}
+
+// attachments lists the files in the folder of the entry for date, other than the entry itself.
+func (c *Config) attachments(date entry.Date) ([]string, error) {
+	dir := c.Layout.Dir(date)
+	files, err := fs.ReadDir(c.FS, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		// Hidden files are not attachments, but files such as those left by an interrupted write.
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || path.Join(dir, f.Name()) == c.Layout.Path(date) {
+			continue
+		}
+		names = append(names, f.Name())
+	}
+	return names, nil
+}
+
+// findAttachment returns the date of the entry the attachment at p belongs to.
+func (c *Config) findAttachment(p string) (entry.Date, error) {
+	dates, err := c.Layout.List(c.FS)
+	if err != nil {
+		return entry.Date{}, err
+	}
+	p = path.Clean(p)
+	for _, date := range dates {
+		if c.Layout.Dir(date) != path.Dir(p) {
+			continue
+		}
+		names, err := c.attachments(date)
+		if err != nil {
+			return entry.Date{}, err
+		}
+		for _, name := range names {
+			if name == path.Base(p) {
+				return date, nil
+			}
+		}
+	}
+	return entry.Date{}, fmt.Errorf("%s is not an attachment of any entry", p)
+}
+
+// renameAttachment renames the attachment at p to name, changing the entry's mentions of it,
+// and returns how many it changed.
+func (c *Config) renameAttachment(p, name string) (int, error) {
+	if err := checkAttachmentName(name); err != nil {
+		return 0, err
+	}
+	date, err := c.findAttachment(p)
+	if err != nil {
+		return 0, err
+	}
+	e, raw, err := c.readEntry(date)
+	if err != nil {
+		return 0, err
+	}
+	dir, from := path.Split(path.Clean(p))
+	to := path.Join(dir, name)
+	if exists(c.FS, to) {
+		return 0, fmt.Errorf("%s already exists", to)
+	}
+
+	if err := c.FS.Rename(path.Join(dir, from), to); err != nil {
+		return 0, err
+	}
+	before := e
+	before.Sections = append([]entry.Section(nil), e.Sections...)
+	renamed := e.RenameFile(from, name)
+	if renamed > 0 {
+		if err := c.writeEntry(date, e, raw); err != nil {
+			// Put the file back, so the entry's links still find it.
+			c.FS.Rename(to, path.Join(dir, from))
+			return 0, err
+		}
+	}
+	return renamed, c.autoCommit(date, &before, &e, path.Join(dir, from), to)
+}
+
+// removeAttachment removes the attachment at p and the lines of its entry that only link to it.
+// It returns the titles of the sections still mentioning it.
+func (c *Config) removeAttachment(p string) ([]string, error) {
+	date, err := c.findAttachment(p)
+	if err != nil {
+		return nil, err
+	}
+	e, raw, err := c.readEntry(date)
+	if err != nil {
+		return nil, err
+	}
+	p = path.Clean(p)
+
+	before := e
+	before.Sections = append([]entry.Section(nil), e.Sections...)
+	if e.RemoveLinks(path.Base(p)) > 0 {
+		if err := c.writeEntry(date, e, raw); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.FS.Remove(p); err != nil {
+		return nil, err
+	}
+	return e.Mentions(path.Base(p)), c.autoCommit(date, &before, &e, p)
+}
+
+// checkAttachmentName checks that name can be the name of a file in an entry's folder.
+func checkAttachmentName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%q is not a file name", name)
+	}
+	if strings.HasPrefix(name, ".") {
+		return fmt.Errorf("attachments cannot be hidden files like %q", name)
+	}
+	return nil
+}
+
+func exists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
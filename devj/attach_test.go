@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+func TestConfig_Attachments(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 2)
+	conf := &Config{FS: fsys}
+	date, _ := entry.ParseDate("2019-01-01")
+
+	for _, expected := range []string{"2019-01-01/diagram.png", "2019-01-01/diagram-1.png"} {
+		stored, err := conf.attach(date, []byte("png"), "diagram.png", "Learn")
+		if stored != expected || err != nil {
+			t.Errorf(`Actual: "%v", %v Expected: "%v"`, stored, err, expected)
+		}
+	}
+	if _, err := conf.attach(date, []byte("x"), "../escape.txt", "Learn"); err == nil {
+		t.Errorf("attached a file outside the entry's folder")
+	}
+	e, _, _ := conf.readEntry(date)
+	expectedBody := "see notes-0.txt\n\n- ![diagram.png](diagram.png)\n- ![diagram-1.png](diagram-1.png)"
+	if e.Sections[1].Body != expectedBody {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, e.Sections[1].Body, expectedBody)
+	}
+
+	names, err := conf.attachments(date)
+	if expected := []string{"diagram-1.png", "diagram.png", "notes-0.txt"}; !reflect.DeepEqual(names, expected) || err != nil {
+		t.Errorf(`Actual: "%v", %v Expected: "%v"`, names, err, expected)
+	}
+
+	// Renaming changes the links, but not the names containing the old one.
+	if n, err := conf.renameAttachment("2019-01-01/diagram.png", "arch.png"); n != 2 || err != nil {
+		t.Errorf("renamed %d links with error %v", n, err)
+	}
+	if _, err := conf.renameAttachment("2019-01-01/arch.png", "diagram-1.png"); err == nil {
+		t.Errorf("renamed an attachment over another")
+	}
+	e, _, _ = conf.readEntry(date)
+	expectedBody = "see notes-0.txt\n\n- ![arch.png](arch.png)\n- ![diagram-1.png](diagram-1.png)"
+	if e.Sections[1].Body != expectedBody || !exists(fsys, "2019-01-01/arch.png") {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, e.Sections[1].Body, expectedBody)
+	}
+
+	// Removing drops the lines linking to it, and reports other mentions.
+	if mentions, err := conf.removeAttachment("2019-01-01/arch.png"); mentions != nil || err != nil {
+		t.Errorf("removing arch.png left mentions %v with error %v", mentions, err)
+	}
+	mentions, err := conf.removeAttachment("2019-01-01/notes-0.txt")
+	if !reflect.DeepEqual(mentions, []string{"Learn"}) || err != nil {
+		t.Errorf("removing notes-0.txt left mentions %v with error %v", mentions, err)
+	}
+	e, _, _ = conf.readEntry(date)
+	expectedBody = "see notes-0.txt\n\n- ![diagram-1.png](diagram-1.png)"
+	if e.Sections[1].Body != expectedBody || exists(fsys, "2019-01-01/arch.png") {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, e.Sections[1].Body, expectedBody)
+	}
+
+	for _, p := range []string{"2019-01-01/2019-01-01.md", "2019-01-02/missing.png", "other/notes-1.txt"} {
+		if _, err := conf.removeAttachment(p); err == nil {
+			t.Errorf("removed %s, which is not an attachment", p)
+		}
+	}
+}
+
+// readOnlyEntriesFS fails to replace files, as when an entry cannot be written.
+type readOnlyEntriesFS struct {
+	*fstest.MemFS
+}
+
+func (readOnlyEntriesFS) WriteFile(name string, b []byte) error {
+	return fmt.Errorf("write %s: permission denied", name)
+}
+
+func TestConfig_Attach_EntryNotWritten(t *testing.T) {
+	fsys := readOnlyEntriesFS{fstest.NewMemFS()}
+	writeJournal(t, fsys.MemFS, 1)
+	conf := &Config{FS: fsys}
+	date, _ := entry.ParseDate("2019-01-01")
+
+	if _, err := conf.attach(date, []byte("png"), "diagram.png", "Learn"); err == nil {
+		t.Fatalf("attached a file to an entry that could not be written")
+	}
+	if names, _ := conf.attachments(date); !reflect.DeepEqual(names, []string{"notes-0.txt"}) {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, names, []string{"notes-0.txt"})
+	}
+}
+
+func TestConfig_Attach_Encrypted(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 1)
+	fsys.WriteFile(configFile, []byte(`{"public_sections": {"do": true}, "encryption": {"mode": "private"}}`))
+	t.Setenv(passphraseEnv, "correct horse")
+	conf, err := ReadConfig(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date, _ := entry.ParseDate("2019-01-01")
+
+	if _, err := conf.attach(date, []byte("secret"), "people.txt", "Learn"); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := fsys.ReadFile("2019-01-01/2019-01-01.md"); strings.Contains(string(raw), "people.txt") {
+		t.Errorf("the link in a private section was written in plain text:\n%s", raw)
+	}
+	e, _, err := conf.readEntry(date)
+	if err != nil || !strings.HasSuffix(e.Sections[1].Body, "- [people.txt](people.txt)") {
+		t.Errorf("read %+v with error %v", e, err)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ifo/dev.journal/cache"
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// manifestName is the file in a backup archive listing the SHA-256 checksum of every other file in it,
+// in the format of sha256sum.
+const manifestName = "MANIFEST.sha256"
+
+// backupTimeFormat names each backup archive after the time it was made, to the nanosecond,
+// so that backups made within the same second get names of their own.
+const backupTimeFormat = "devj-20060102-150405.000000000.tar.gz"
+
+// backupParseFormat reads the names of backupTimeFormat, and of archives named to the second only
+// by earlier versions.
+const backupParseFormat = "devj-20060102-150405.999999999.tar.gz"
+
+// BackupConfig controls where devj backup keeps its archives, and how many it keeps.
+type BackupConfig struct {
+	// Dir is the folder of the archives, relative to the journal root.
+	Dir string `json:"dir"`
+	// Keep is the number of newest archives kept when a new one is made. Zero keeps them all.
+	Keep int `json:"keep"`
+	// KeepDays is the number of days archives are kept for. Zero keeps them however old they are.
+	KeepDays int `json:"keep_days"`
+}
+
+// BackupCommand writes an archive of the whole journal, and then prunes old archives.
+func BackupCommand(conf *Config, args []string) error {
+	unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	archive, err := BuildArchive(conf.FS, conf.Backup.Dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	name := path.Join(conf.Backup.Dir, now.Format(backupTimeFormat))
+	if err := filesystem.EnsureFolderExists(conf.FS, conf.Backup.Dir); err != nil {
+		return err
+	}
+	// An archive already at name is never replaced.
+	if err := filesystem.SafeWriteFile(conf.FS, name, archive); err != nil {
+		return err
+	}
+	fmt.Printf("backup written to %s\n", name)
+
+	pruned, err := pruneBackups(conf.FS, conf.Backup, now)
+	for _, p := range pruned {
+		fmt.Printf("removed old backup %s\n", p)
+	}
+	return err
+}
+
+// RestoreCommand copies the files of a backup archive into the journal, leaving alone those already there.
+// Files that are there but differ are reported as conflicts.
+func RestoreCommand(conf *Config, args []string) error {
+	archivePath := args[0]
+	if !filepath.IsAbs(archivePath) {
+		archivePath = filepath.Join(workDir, archivePath)
+	}
+	archive, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+	files, err := ReadArchive(archive)
+	if err != nil {
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+
+	unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	restored, conflicts, err := restoreFiles(conf.FS, conf.Layout, files)
+	for _, c := range conflicts {
+		fmt.Println(c)
+	}
+	if err != nil {
+		return err
+	}
+	identical := len(files) - len(restored) - len(conflicts)
+	fmt.Printf("%s restored, %s identical to the backup, %s\n",
+		plural(len(restored), "file"), plural(identical, "file"), plural(len(conflicts), "conflict"))
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%s with the backup, the journal's files were left as they are", plural(len(conflicts), "conflict"))
+	}
+	return nil
+}
+
+// BuildArchive makes a gzipped tar archive of every file in fsys, starting with a manifest of their checksums.
+// The archives in backupDir, the cache, the lock file and any git repository are left out.
+func BuildArchive(fsys filesystem.FS, backupDir string) ([]byte, error) {
+	skip := map[string]bool{".git": true, cache.FileName: true, filesystem.LockFile: true, path.Clean(backupDir): true}
+	files := map[string][]byte{}
+	var names []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skip[name] {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if files[name], err = fsys.ReadFile(name); err != nil {
+			return err
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&manifest, "%s  %s\n", cache.Hash(files[name]), name)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	write := func(name string, b []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(b)
+		return err
+	}
+	if err := write(manifestName, []byte(manifest.String())); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := write(name, files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadArchive reads the files of an archive made by BuildArchive, checking them against its manifest.
+func ReadArchive(archive []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// Names are kept inside the journal.
+		if !fs.ValidPath(hdr.Name) || hdr.Name == "." {
+			return nil, fmt.Errorf("bad file name %q in archive", hdr.Name)
+		}
+		if files[hdr.Name], err = ioutil.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, ok := files[manifestName]
+	if !ok {
+		return nil, fmt.Errorf("archive has no %s", manifestName)
+	}
+	delete(files, manifestName)
+	listed := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "  ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad line %q in %s", scanner.Text(), manifestName)
+		}
+		sum, name := parts[0], parts[1]
+		b, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is in %s but not in the archive", name, manifestName)
+		}
+		if cache.Hash(b) != sum {
+			return nil, fmt.Errorf("%s does not match its checksum, the archive is damaged", name)
+		}
+		listed[name] = true
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, fmt.Errorf("%s is in the archive but not in %s", name, manifestName)
+		}
+	}
+	return files, nil
+}
+
+// restoreFiles writes every file missing from fsys, and returns the names of those it wrote.
+// Files already in fsys are never changed; those that differ are described as conflicts instead,
+// naming the sections that differ for entries.
+func restoreFiles(fsys filesystem.FS, layout filesystem.Layout, files map[string][]byte) ([]string, []string, error) {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var restored, conflicts []string
+	for _, name := range names {
+		existing, err := fsys.ReadFile(name)
+		if err == nil {
+			if !bytes.Equal(existing, files[name]) {
+				conflicts = append(conflicts, restoreConflict(layout, name, existing, files[name]))
+			}
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return restored, conflicts, err
+		}
+		if err := filesystem.EnsureFolderExists(fsys, path.Dir(name)); err != nil {
+			return restored, conflicts, err
+		}
+		if err := filesystem.SafeWriteFile(fsys, name, files[name]); err != nil {
+			return restored, conflicts, err
+		}
+		restored = append(restored, name)
+	}
+	return restored, conflicts, nil
+}
+
+func restoreConflict(layout filesystem.Layout, name string, existing, archived []byte) string {
+	date, err := layout.Date(name)
+	if err != nil {
+		return fmt.Sprintf("%s: differs from the backup", name)
+	}
+	e, err1 := entry.Import(string(existing))
+	e2, err2 := entry.Import(string(archived))
+	if err1 != nil || err2 != nil {
+		return fmt.Sprintf("%s: differs from the backup", date)
+	}
+	if changed := e.ChangedSections(e2); len(changed) > 0 {
+		return fmt.Sprintf("%s: differs from the backup in %s", date, strings.Join(changed, ", "))
+	}
+	// Only the formatting differs.
+	return fmt.Sprintf("%s: differs from the backup", date)
+}
+
+// pruneBackups removes the archives in conf.Dir that conf no longer keeps as of now,
+// and returns the names of those it removed.
+func pruneBackups(fsys filesystem.FS, conf BackupConfig, now time.Time) ([]string, error) {
+	names, err := filesystem.ListFiles(fsys, conf.Dir)
+	if err != nil {
+		return nil, err
+	}
+	type archive struct {
+		name string
+		made time.Time
+	}
+	var archives []archive
+	for _, name := range names {
+		if made, err := time.ParseInLocation(backupParseFormat, name, now.Location()); err == nil {
+			archives = append(archives, archive{name, made})
+		}
+	}
+	// Newest first.
+	sort.Slice(archives, func(i, k int) bool { return archives[i].made.After(archives[k].made) })
+
+	var removed []string
+	for i, a := range archives {
+		tooMany := conf.Keep > 0 && i >= conf.Keep
+		tooOld := conf.KeepDays > 0 && now.Sub(a.made) > time.Duration(conf.KeepDays)*24*time.Hour
+		if !tooMany && !tooOld {
+			continue
+		}
+		name := path.Join(conf.Dir, a.name)
+		if err := fsys.Remove(name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
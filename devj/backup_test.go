@@ -0,0 +1,164 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifo/dev.journal/filesystem"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+func TestBackupRestore(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 3)
+	for name, contents := range map[string]string{configFile: "{}", ".devj-cache": "{}", ".devj-archives/old.tar.gz": "old"} {
+		fsys.MkdirAll(".devj-archives")
+		if err := fsys.WriteFile(name, []byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive, err := BuildArchive(fsys, ".devj-archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ReadArchive(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	expected := []string{".devj", "2019-01-01/2019-01-01.md", "2019-01-01/notes-0.txt"}
+	if len(names) != 7 || !containsAll(names, expected) {
+		t.Errorf("archived %v, expected the config and 3 entries with their notes", names)
+	}
+
+	// Restoring into an empty journal writes every file.
+	empty := fstest.NewMemFS()
+	restored, conflicts, err := restoreFiles(empty, filesystem.Layout{}, files)
+	if err != nil || len(restored) != 7 || conflicts != nil {
+		t.Errorf("restored %v with conflicts %v and error %v", restored, conflicts, err)
+	}
+	if !reflect.DeepEqual(empty.Names(), restored) {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, empty.Names(), restored)
+	}
+
+	// Restoring over the journal writes only what is missing, and reports what differs.
+	fsys.WriteFile("2019-01-02/2019-01-02.md", []byte("# Do\n\nsomething else\n\n# Learn\n\nsee notes-1.txt\n"))
+	fsys.WriteFile("2019-01-02/notes-1.txt", []byte("changed"))
+	fsys.Remove("2019-01-03/notes-2.txt")
+	restored, conflicts, err = restoreFiles(fsys, filesystem.Layout{}, files)
+	if err != nil || !reflect.DeepEqual(restored, []string{"2019-01-03/notes-2.txt"}) {
+		t.Errorf("restored %v with error %v", restored, err)
+	}
+	expected = []string{"2019-01-02: differs from the backup in Do", "2019-01-02/notes-1.txt: differs from the backup"}
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, conflicts, expected)
+	}
+	if b, _ := fsys.ReadFile("2019-01-02/notes-1.txt"); string(b) != "changed" {
+		t.Errorf("a conflicting file was overwritten")
+	}
+}
+
+func TestBackupCommand_SameSecond(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 1)
+	conf := &Config{FS: fsys, Backup: BackupConfig{Dir: "archives"}}
+	// Backups made one after the other are kept apart, rather than the second failing or replacing the first.
+	for i := 0; i < 2; i++ {
+		if err := BackupCommand(conf, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archives, _ := filesystem.ListFiles(fsys, "archives")
+	if len(archives) != 2 {
+		t.Errorf("made %v, expected 2 archives", archives)
+	}
+}
+
+func TestReadArchive_Damaged(t *testing.T) {
+	tests := map[string]struct {
+		Files map[string]string
+		Err   string
+	}{
+		"no manifest": {
+			Files: map[string]string{"a.md": "a"},
+			Err:   "archive has no MANIFEST.sha256"},
+		"changed": {
+			Files: map[string]string{manifestName: "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.md\n", "a.md": "b"},
+			Err:   "a.md does not match its checksum, the archive is damaged"},
+		"unlisted": {
+			Files: map[string]string{manifestName: "", "a.md": "a"},
+			Err:   "a.md is in the archive but not in MANIFEST.sha256"},
+		"outside": {
+			Files: map[string]string{"../a.md": "a"},
+			Err:   `bad file name "../a.md" in archive`},
+	}
+
+	for id, test := range tests {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for name, contents := range test.Files {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+			tw.Write([]byte(contents))
+		}
+		tw.Close()
+		gz.Close()
+
+		if _, err := ReadArchive(buf.Bytes()); err == nil || err.Error() != test.Err {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, err, test.Err, id)
+		}
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	now := time.Date(2019, 1, 31, 12, 0, 0, 0, time.Local)
+	fsys := fstest.NewMemFS()
+	fsys.MkdirAll("archives")
+	for _, days := range []int{0, 1, 2, 10, 40} {
+		fsys.WriteFile("archives/"+now.AddDate(0, 0, -days).Format(backupTimeFormat), nil)
+	}
+	// Archives named to the second only are pruned as well.
+	fsys.WriteFile("archives/devj-20181201-120000.tar.gz", nil)
+	fsys.WriteFile("archives/notes.txt", nil)
+
+	removed, err := pruneBackups(fsys, BackupConfig{Dir: "archives", Keep: 3, KeepDays: 30}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"archives/devj-20190121-120000.000000000.tar.gz", "archives/devj-20181222-120000.000000000.tar.gz",
+		"archives/devj-20181201-120000.tar.gz"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, removed, expected)
+	}
+	if names := fsys.Names(); len(names) != 4 || !strings.HasSuffix(names[3], "notes.txt") {
+		t.Errorf("kept %v, expected 3 archives and notes.txt", names)
+	}
+
+	// Age alone is enough to be pruned.
+	removed, err = pruneBackups(fsys, BackupConfig{Dir: "archives", KeepDays: 1}, now)
+	if err != nil || !reflect.DeepEqual(removed, []string{"archives/devj-20190129-120000.000000000.tar.gz"}) {
+		t.Errorf("removed %v with error %v, expected only the archive from two days ago", removed, err)
+	}
+}
+
+func containsAll(list, want []string) bool {
+	have := map[string]bool{}
+	for _, s := range list {
+		have[s] = true
+	}
+	for _, s := range want {
+		if !have[s] {
+			return false
+		}
+	}
+	return true
+}
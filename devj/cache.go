@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ifo/dev.journal/cache"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// CacheCommand rebuilds or verifies the journal's cache, as sub says.
+func CacheCommand(sub string) runFunc {
+	return func(conf *Config, args []string) error {
+		return cacheCommand(conf, sub)
+	}
+}
+
+// openCache opens the cache of the journal in fsys. Whole entries are only cached if the journal
+// is not encrypted, so the cache never holds in plain text what the entry files hold encrypted.
+func (c *Config) openCache(fsys filesystem.FS) *cache.Cache {
+	return cache.Open(fsys, c.PublicSections, c.Encryption.Mode == "")
+}
+
+func cacheCommand(conf *Config, sub string) error {
+	dates, err := conf.Layout.List(conf.FS)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for _, date := range dates {
+		paths = append(paths, conf.Layout.Path(date))
+	}
+
+	ch := conf.openCache(conf.FS)
+	switch sub {
+	case "rebuild":
+		ch.Reset()
+		for i, path := range paths {
+			if _, err := ch.Lookup(path, conf.Layout.Dir(dates[i])); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+		}
+		if err := ch.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("cached %d entries\n", len(paths))
+
+	case "verify":
+		stale := ch.Verify()
+		for _, path := range ch.Missing(paths) {
+			stale = append(stale, fmt.Sprintf("%s: not cached", path))
+		}
+		for _, s := range stale {
+			fmt.Println(s)
+		}
+		if len(stale) > 0 {
+			return fmt.Errorf("%d cached entries are out of date; run devj cache rebuild", len(stale))
+		}
+		fmt.Printf("all %d entries are cached and up to date\n", len(paths))
+	}
+	return nil
+}
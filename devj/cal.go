@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+// CalDay is what the calendar shows about a day with an entry.
+type CalDay struct {
+	Words  int
+	Public bool
+}
+
+// CalCommand prints a month of the journal as a calendar, or lets the user move around it
+// and open entries when run with -i.
+func CalCommand(fs *flag.FlagSet, _ *Config) runFunc {
+	interactive := fs.Bool("i", false, "move between days with the arrow keys and open them with Enter")
+	return func(conf *Config, args []string) error {
+		today := conf.Today()
+		month := today.StartOfMonth()
+		if len(args) == 1 {
+			var err error
+			if month, _, err = entry.ParseMonth(args[0]); err != nil {
+				return usagef("%v", err)
+			}
+		}
+
+		days, err := calendarDays(conf)
+		if err != nil {
+			return err
+		}
+		if !*interactive {
+			fmt.Print(RenderCalendar(month, days, entry.Date{}))
+			return nil
+		}
+
+		selected := month
+		if today.StartOfMonth() == month {
+			selected = today
+		}
+		return browseCalendar(conf, selected, days)
+	}
+}
+
+// calendarDays describes every entry in the journal, by date.
+func calendarDays(conf *Config) (map[entry.Date]CalDay, error) {
+	jrn, err := conf.LoadJournal(conf.FS)
+	if err != nil {
+		return nil, err
+	}
+	days := map[entry.Date]CalDay{}
+	for _, e := range jrn.Ordered() {
+		d, err := e.Name.Date()
+		if err != nil {
+			return nil, err
+		}
+		words := 0
+		for _, s := range e.Sections {
+			words += countWords(s.Body)
+		}
+		days[d] = CalDay{Words: words, Public: len(e.Public(conf.PublicSections).Sections) > 0}
+	}
+	return days, nil
+}
+
+// RenderCalendar draws the month holding month as a grid of weeks starting on Monday.
+// Each day with an entry is marked by how long it is, and by whether it has public sections.
+// The selected day, if not zero, is put in brackets.
+func RenderCalendar(month entry.Date, days map[entry.Date]CalDay, selected entry.Date) string {
+	first := month.StartOfMonth()
+	var b strings.Builder
+	title := fmt.Sprintf("%s %d", first.Month(), first.Year())
+	fmt.Fprintf(&b, "%*s\n", (7*6+len(title))/2, title)
+	for _, name := range []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"} {
+		fmt.Fprintf(&b, "  %s  ", name)
+	}
+	b.WriteString("\n")
+
+	b.WriteString(strings.Repeat("      ", (int(first.Weekday())+6)%7))
+	for d := first; d.Month() == first.Month(); d = d.AddDays(1) {
+		length, public := " ", " "
+		if cd, ok := days[d]; ok {
+			length = lengthMark(cd.Words)
+			if cd.Public {
+				public = "+"
+			}
+		}
+		open, close := " ", " "
+		if d == selected {
+			open, close = "[", "]"
+		}
+		fmt.Fprintf(&b, "%s%2d%s%s%s", open, d.Day(), length, public, close)
+		if d.Weekday() == time.Sunday {
+			b.WriteString("\n")
+		}
+	}
+	if first.EndOfMonth().Weekday() != time.Sunday {
+		b.WriteString("\n")
+	}
+	b.WriteString("\n. short  o medium  O long  + has public sections\n")
+	return b.String()
+}
+
+func lengthMark(words int) string {
+	switch {
+	case words < 50:
+		return "."
+	case words < 250:
+		return "o"
+	default:
+		return "O"
+	}
+}
+
+// browseCalendar runs the interactive calendar until the user quits.
+func browseCalendar(conf *Config, selected entry.Date, days map[entry.Date]CalDay) error {
+	restore, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	defer func() { restore() }()
+
+	message := ""
+	buf := make([]byte, 8)
+	for {
+		cal := RenderCalendar(selected, days, selected)
+		cal += "\narrows or hjkl move, Enter opens, q quits\n" + message
+		fmt.Print("\x1b[H\x1b[2J" + strings.Replace(cal, "\n", "\r\n", -1))
+		message = ""
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		key := calKey(buf[:n])
+		switch key {
+		case "quit":
+			fmt.Print("\r\n")
+			return nil
+		case "enter":
+			if _, ok := days[selected]; !ok {
+				message = fmt.Sprintf("no entry for %s\n", selected)
+				continue
+			}
+			restore()
+			if err := openEntry(conf, selected); err != nil {
+				message = fmt.Sprintf("%v\n", err)
+			}
+			// The entry may have changed, so read the journal again.
+			if days, err = calendarDays(conf); err != nil {
+				return err
+			}
+			again, err := rawTerminal()
+			if err != nil {
+				return err
+			}
+			restore = again
+		default:
+			selected = moveDay(selected, key)
+		}
+	}
+}
+
+// calKey names the key pressed, as read from a terminal in raw mode, or returns "" for keys with no use.
+func calKey(b []byte) string {
+	switch string(b) {
+	case "\x1b[D", "h":
+		return "left"
+	case "\x1b[C", "l":
+		return "right"
+	case "\x1b[A", "k":
+		return "up"
+	case "\x1b[B", "j":
+		return "down"
+	case "\r", "\n":
+		return "enter"
+	case "q", "\x1b", "\x03":
+		return "quit"
+	}
+	return ""
+}
+
+// moveDay moves a day to the sides, or a week up and down.
+func moveDay(d entry.Date, key string) entry.Date {
+	switch key {
+	case "left":
+		return d.AddDays(-1)
+	case "right":
+		return d.AddDays(1)
+	case "up":
+		return d.AddDays(-7)
+	case "down":
+		return d.AddDays(7)
+	}
+	return d
+}
+
+// rawTerminal puts the terminal in raw mode, so keys can be read as they are pressed,
+// and returns a function putting it back as it was.
+func rawTerminal() (func(), error) {
+	cmd := exec.Command("stty", "-g")
+	cmd.Stdin = os.Stdin
+	state, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("interactive mode needs a terminal: %v", err)
+	}
+	cmd = exec.Command("stty", "raw", "-echo")
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return func() {
+		cmd := exec.Command("stty", strings.TrimSpace(string(state)))
+		cmd.Stdin = os.Stdin
+		cmd.Run()
+	}, nil
+}
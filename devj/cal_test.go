@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+func TestRenderCalendar(t *testing.T) {
+	date := func(s string) entry.Date {
+		d, _ := entry.ParseDate(s)
+		return d
+	}
+	days := map[entry.Date]CalDay{
+		date("2026-02-02"): {Words: 10},
+		date("2026-02-03"): {Words: 100, Public: true},
+		date("2026-02-28"): {Words: 1000, Public: true},
+	}
+
+	expected := "              February 2026\n" +
+		"  Mo    Tu    We    Th    Fr    Sa    Su  \n" +
+		"                                      1   \n" +
+		"  2.  [ 3o+]  4     5     6     7     8   \n" +
+		"  9    10    11    12    13    14    15   \n" +
+		" 16    17    18    19    20    21    22   \n" +
+		" 23    24    25    26    27    28O+ \n" +
+		"\n. short  o medium  O long  + has public sections\n"
+	if out := RenderCalendar(date("2026-02-14"), days, date("2026-02-03")); out != expected {
+		t.Errorf("Actual:\n%s\nExpected:\n%s", out, expected)
+	}
+}
+
+func TestMoveDay(t *testing.T) {
+	start, _ := entry.ParseDate("2026-10-31")
+
+	tests := map[string]struct {
+		Keys string
+		Out  string
+	}{
+		"arrows":       {Keys: "\x1b[A", Out: "2026-10-24"},
+		"next month":   {Keys: "\x1b[C", Out: "2026-11-01"},
+		"vi keys":      {Keys: "jjh", Out: "2026-11-13"},
+		"unknown keys": {Keys: "xyz", Out: "2026-10-31"},
+	}
+
+	for id, test := range tests {
+		d := start
+		keys := test.Keys
+		for keys != "" {
+			n := 1
+			if keys[0] == '\x1b' {
+				n = 3
+			}
+			d = moveDay(d, calKey([]byte(keys[:n])))
+			keys = keys[n:]
+		}
+		if out := d.String(); out != test.Out {
+			t.Errorf(`Actual: "%s" Expected: "%s" Case: %q`, out, test.Out, id)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// The exit codes of devj.
+const (
+	exitOK    = 0
+	exitError = 1
+	// exitUsage is for commands run the wrong way, such as with an unknown flag or too few arguments.
+	exitUsage = 2
+)
+
+// runFunc runs a command with the arguments left after its flags.
+type runFunc func(conf *Config, args []string) error
+
+// flagsFunc declares the flags of a command on fs, with defaults from the config defaults where it has them,
+// and returns what runs the command once they are parsed. The config to run with is given to the runFunc.
+type flagsFunc func(fs *flag.FlagSet, defaults *Config) runFunc
+
+// command is something devj does, such as devj new.
+type command struct {
+	name    string
+	summary string
+	// args describes the arguments after the flags, for the command's usage.
+	args string
+	// minArgs and maxArgs bound the number of arguments after the flags. A negative maxArgs has no bound.
+	minArgs, maxArgs int
+	// interspersed lets flags come after the arguments too, which is otherwise where flags stop.
+	interspersed bool
+	// Commands with flags give flags, and those without give run.
+	flags flagsFunc
+	run   runFunc
+	// subcommands are what commands like devj cache do, such as devj cache rebuild.
+	subcommands []*command
+	// noJournal commands also run outside a journal, with a nil config.
+	noJournal bool
+	// hidden commands are left out of usage and completion, as they are for scripts rather than people.
+	hidden bool
+}
+
+var commands = []*command{
+	{name: "new", summary: "make today's entry, starting from the latest one", run: NewCommand},
+	{name: "edit", summary: "open the latest entry in the editor", run: EditCommand},
+	{name: "attach", summary: "add a file to today's entry and link to it, committing both if git auto_commit is on (devj has no add)", args: "<file>",
+		minArgs: 1, maxArgs: 1, interspersed: true, flags: AttachCommand},
+	{name: "attachments", summary: "list, rename or remove the attachments of entries", subcommands: []*command{
+		{name: "list", summary: "list the attachments of every entry, and what links to them", run: AttachmentsCommand("list")},
+		{name: "rename", summary: "rename an attachment, and the links to it", args: "<attachment> <new name>",
+			minArgs: 2, maxArgs: 2, run: AttachmentsCommand("rename")},
+		{name: "remove", summary: "remove an attachment, and the lines linking to it", args: "<attachment>",
+			minArgs: 1, maxArgs: 1, run: AttachmentsCommand("remove")},
+	}},
+	{name: "gc", summary: "find attachments nothing links to, and attachments left out of exports", flags: GCCommand},
+	{name: "search", summary: "search every entry, private sections included", args: "<query>...",
+		minArgs: 1, maxArgs: -1, flags: SearchCommand},
+	{name: "cal", summary: "show a month of the journal as a calendar", args: "[YYYY-MM]", maxArgs: 1, flags: CalCommand},
+	{name: "report", summary: "gather the public sections of a week or month into a report", flags: ReportCommand},
+	{name: "standup", summary: "print a standup from yesterday's and today's entries", flags: StandupCommand},
+	{name: "stats", summary: "show how much, and how regularly, the journal is written in", flags: StatsCommand},
+	{name: "export", summary: "send the public parts of the journal to a devj server", flags: ExportCommand},
+	{name: "section", summary: "rename, merge or split sections across entries", subcommands: []*command{
+		{name: "rename", summary: "rename a section", args: "<old> <new>", minArgs: 2, maxArgs: 2, flags: SectionCommand("rename")},
+		{name: "merge", summary: "merge sections into one", args: "<into> <from>...", minArgs: 2, maxArgs: -1, flags: SectionCommand("merge")},
+		{name: "split", summary: "split a section by the prefixes of its lines", args: "<title>",
+			minArgs: 1, maxArgs: 1, flags: SectionCommand("split")},
+	}},
+	{name: "relayout", summary: "move every entry to a new layout", args: "<layout>", minArgs: 1, maxArgs: 1, flags: RelayoutCommand},
+	{name: "cache", summary: "rebuild or verify the cache of parsed entries", subcommands: []*command{
+		{name: "rebuild", summary: "parse every entry again", run: CacheCommand("rebuild")},
+		{name: "verify", summary: "check that every cached entry is up to date", run: CacheCommand("verify")},
+	}},
+	{name: "sync", summary: "pull and push the journal's git repository", args: "[remote]", maxArgs: 1, run: SyncCommand},
+	{name: "backup", summary: "write an archive of the journal, and prune old ones", run: BackupCommand},
+	{name: "restore", summary: "copy the files of a backup missing from the journal back", args: "<archive>",
+		minArgs: 1, maxArgs: 1, run: RestoreCommand},
+	{name: "encrypt", summary: "encrypt every entry as the config asks", run: EncryptCommand},
+	{name: "viewconfig", summary: "print the journal's config", run: func(conf *Config, args []string) error {
+		fmt.Println(conf)
+		return nil
+	}},
+	{name: "completion", summary: "print a script completing devj commands for bash, zsh or fish", args: "<shell>",
+		minArgs: 1, maxArgs: 1, noJournal: true, run: CompletionCommand},
+}
+
+// usageError is a command being run the wrong way, such as with a bad flag value.
+// It is reported along with the command's usage.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func usagef(format string, a ...interface{}) error {
+	return usageError(fmt.Sprintf(format, a...))
+}
+
+// run runs devj with args, reporting how it went on stdout and stderr, and returns its exit code.
+// The commands print their own output to os.Stdout.
+func run(args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("devj", flag.ContinueOnError)
+	global.SetOutput(ioutil.Discard)
+	rootFlag := global.String("root", "", "the journal root, instead of $"+rootEnv+" or the closest folder with a "+configFile+" file")
+	if err := global.Parse(args); errors.Is(err, flag.ErrHelp) {
+		printUsage(stdout, global)
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintf(stderr, "devj: %v\n", err)
+		printUsage(stderr, global)
+		return exitUsage
+	}
+	args = global.Args()
+	if len(args) == 0 {
+		printUsage(stderr, global)
+		return exitUsage
+	}
+	if args[0] == "help" {
+		return help(args[1:], stdout, stderr, global)
+	}
+
+	cmd, name, args := findCommand(args)
+	if cmd == nil {
+		fmt.Fprintf(stderr, "devj: unknown command %q\n", name)
+		printUsage(stderr, global)
+		return exitUsage
+	}
+	if cmd.subcommands != nil {
+		if len(args) > 0 {
+			fmt.Fprintf(stderr, "devj %s: unknown subcommand %q\n", name, args[0])
+		}
+		printCommandUsage(stderr, cmd, name)
+		return exitUsage
+	}
+
+	// The config gives the defaults of some flags, so it is read first, but a journal is only
+	// needed once the flags are parsed. Until then, the defaults of an empty config stand in for it.
+	conf, journalErr := openJournal(*rootFlag)
+	if journalErr != nil {
+		conf = defaultConfig()
+	}
+	fs := flag.NewFlagSet("devj "+name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	runCmd := cmd.run
+	if cmd.flags != nil {
+		runCmd = cmd.flags(fs, conf)
+	}
+
+	args, err := parseFlags(fs, args, cmd.interspersed)
+	if errors.Is(err, flag.ErrHelp) {
+		printCommandUsage(stdout, cmd, name)
+		return exitOK
+	}
+	if err == nil && (len(args) < cmd.minArgs || cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		err = usagef("wrong number of arguments")
+	}
+	if err == nil {
+		if journalErr != nil && !cmd.noJournal {
+			fmt.Fprintf(stderr, "devj: %v\n", journalErr)
+			return exitError
+		}
+		if journalErr != nil {
+			conf = nil
+		}
+		err = runCmd(conf, args)
+	}
+
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "devj %s: %v\n", name, err)
+		printCommandUsage(stderr, cmd, name)
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "devj %s: %v\n", name, err)
+		return exitError
+	}
+}
+
+// help prints the usage of devj, or of the command named by args.
+func help(args []string, stdout, stderr io.Writer, global *flag.FlagSet) int {
+	if len(args) == 0 {
+		printUsage(stdout, global)
+		return exitOK
+	}
+	cmd, name, rest := findCommand(args)
+	if cmd == nil {
+		fmt.Fprintf(stderr, "devj help: unknown command %q\n", name)
+		return exitUsage
+	}
+	if len(rest) > 0 {
+		fmt.Fprintf(stderr, "devj help: unknown subcommand %q of %s\n", rest[0], name)
+		return exitUsage
+	}
+	printCommandUsage(stdout, cmd, name)
+	return exitOK
+}
+
+// findCommand finds the command args start with, going into subcommands as far as args name them.
+// It returns the command, its full name, such as "cache rebuild", and the args after it.
+// The command is nil if the first of args names none.
+func findCommand(args []string) (*command, string, []string) {
+	name := args[0]
+	cmd := lookupCommand(commands, name)
+	if cmd == nil {
+		return nil, name, nil
+	}
+	args = args[1:]
+	for len(args) > 0 && cmd.subcommands != nil {
+		sub := lookupCommand(cmd.subcommands, args[0])
+		if sub == nil {
+			break
+		}
+		cmd, name, args = sub, name+" "+sub.name, args[1:]
+	}
+	return cmd, name, args
+}
+
+func lookupCommand(cmds []*command, name string) *command {
+	for _, c := range cmds {
+		if c.name == strings.ToLower(name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// openJournal moves into the journal root, so every path devj uses is relative to it, and reads its config.
+func openJournal(flagRoot string) (*Config, error) {
+	root, err := JournalRoot(flagRoot)
+	if err != nil {
+		return nil, err
+	}
+	if workDir, err = os.Getwd(); err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(root); err != nil {
+		return nil, err
+	}
+	return ReadConfig(filesystem.OS(root))
+}
+
+// defaultConfig is the config of a journal with an empty config file.
+func defaultConfig() *Config {
+	var conf *Config
+	if err := json.Unmarshal([]byte("{}"), &conf); err != nil {
+		panic(err)
+	}
+	return conf
+}
+
+// parseFlags parses the flags in args and returns the arguments left.
+// If interspersed, flags may also come after arguments, until a "--".
+// Errors other than flag.ErrHelp are usage errors.
+func parseFlags(fs *flag.FlagSet, args []string, interspersed bool) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		} else if err != nil {
+			return nil, usagef("%v", err)
+		}
+		left := fs.Args()
+		// Parse stops at the first argument, or just after a "--", which ends the flags for good.
+		if parsed := len(args) - len(left); !interspersed || len(left) == 0 || parsed > 0 && args[parsed-1] == "--" {
+			return append(rest, left...), nil
+		}
+		args = left
+		rest, args = append(rest, args[0]), args[1:]
+	}
+}
+
+// printUsage prints how to run devj, and the commands it has.
+func printUsage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintf(w, "usage: devj [-root <dir>] <command> [flags] [args]\n\ncommands:\n")
+	printCommands(w, commands)
+	fmt.Fprintf(w, "\nglobal flags:\n")
+	global.SetOutput(w)
+	global.PrintDefaults()
+	global.SetOutput(ioutil.Discard)
+	fmt.Fprintf(w, "\nRun \"devj help <command>\" for the flags and arguments of a command.\n")
+}
+
+// printCommandUsage prints how to run cmd, named name, and its flags or subcommands.
+func printCommandUsage(w io.Writer, cmd *command, name string) {
+	if cmd.subcommands != nil {
+		fmt.Fprintf(w, "usage: devj %s <subcommand> [flags] [args]\n\n%s\n\nsubcommands:\n", name, cmd.summary)
+		printCommands(w, cmd.subcommands)
+		fmt.Fprintf(w, "\nRun \"devj help %s <subcommand>\" for the flags and arguments of a subcommand.\n", name)
+		return
+	}
+
+	fs := flag.NewFlagSet("devj "+name, flag.ContinueOnError)
+	if cmd.flags != nil {
+		cmd.flags(fs, defaultConfig())
+	}
+	usage := "devj " + name
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		usage += " [flags]"
+	}
+	if cmd.args != "" {
+		usage += " " + cmd.args
+	}
+	fmt.Fprintf(w, "usage: %s\n\n%s\n", usage, cmd.summary)
+	if hasFlags {
+		fmt.Fprintf(w, "\nflags:\n")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+func printCommands(w io.Writer, cmds []*command) {
+	width := 0
+	for _, c := range cmds {
+		if len(c.name) > width && !c.hidden {
+			width = len(c.name)
+		}
+	}
+	for _, c := range cmds {
+		if !c.hidden {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, c.name, c.summary)
+		}
+	}
+}
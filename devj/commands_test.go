@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	// A root with no config file, so no test moves into a journal.
+	dir, err := ioutil.TempDir("", "devj-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := map[string]struct {
+		Args   []string
+		Code   int
+		Stdout string
+		Stderr string
+	}{
+		"no command":          {Args: nil, Code: exitUsage, Stderr: "usage: devj"},
+		"help":                {Args: []string{"help"}, Code: exitOK, Stdout: "standup"},
+		"global -h":           {Args: []string{"-h"}, Code: exitOK, Stdout: "-root"},
+		"help command":        {Args: []string{"help", "report"}, Code: exitOK, Stdout: "-group-by"},
+		"help group":          {Args: []string{"help", "cache"}, Code: exitOK, Stdout: "verify"},
+		"help subcommand":     {Args: []string{"help", "section", "split"}, Code: exitOK, Stdout: "-by-prefix"},
+		"help unknown":        {Args: []string{"help", "nosuch"}, Code: exitUsage, Stderr: `unknown command "nosuch"`},
+		"command -h":          {Args: []string{"-root", dir, "stats", "-h"}, Code: exitOK, Stdout: "-weeks"},
+		"unknown command":     {Args: []string{"nosuch"}, Code: exitUsage, Stderr: `unknown command "nosuch"`},
+		"unknown subcommand":  {Args: []string{"cache", "nosuch"}, Code: exitUsage, Stderr: `unknown subcommand "nosuch"`},
+		"missing subcommand":  {Args: []string{"section"}, Code: exitUsage, Stderr: "subcommands:"},
+		"unknown flag":        {Args: []string{"-root", dir, "gc", "-nosuch"}, Code: exitUsage, Stderr: "-remove"},
+		"too many args":       {Args: []string{"-root", dir, "backup", "extra"}, Code: exitUsage, Stderr: "wrong number of arguments"},
+		"too few args":        {Args: []string{"-root", dir, "search"}, Code: exitUsage, Stderr: "wrong number of arguments"},
+		"no journal":          {Args: []string{"-root", dir, "stats"}, Code: exitError, Stderr: "is not a journal"},
+		"interspersed flag":   {Args: []string{"-root", dir, "attach", "a.txt", "-nosuch"}, Code: exitUsage, Stderr: "-nosuch"},
+		"flags end after --":  {Args: []string{"-root", dir, "attach", "--", "a.txt", "-nosuch"}, Code: exitUsage, Stderr: "wrong number of arguments"},
+		"args after the flag": {Args: []string{"-root", dir, "attach", "-section", "Do", "a.txt"}, Code: exitError, Stderr: "is not a journal"},
+	}
+	for name, test := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(test.Args, &stdout, &stderr)
+		if code != test.Code {
+			t.Errorf("Actual: %d Expected: %d Case: %q\n%s", code, test.Code, name, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), test.Stdout) {
+			t.Errorf("Actual: %q Expected to contain: %q Case: %q", stdout.String(), test.Stdout, name)
+		}
+		if !strings.Contains(stderr.String(), test.Stderr) {
+			t.Errorf("Actual: %q Expected to contain: %q Case: %q", stderr.String(), test.Stderr, name)
+		}
+	}
+}
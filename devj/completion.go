@@ -0,0 +1,351 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// recentEntries is how many of the latest entries the section titles to complete come from.
+const recentEntries = 30
+
+// completionScripts are what devj completion prints for each shell. Each asks devj __complete
+// what can come in place of the word being completed, and completes file names if nothing can.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for devj; load it with: source <(devj completion bash)
+_devj() {
+	local IFS=$'\n'
+	COMPREPLY=($(devj __complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _devj devj
+`,
+	"zsh": `#compdef devj
+# zsh completion for devj; load it with: source <(devj completion zsh)
+_devj() {
+	local -a candidates
+	candidates=(${(f)"$(devj __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	if (( ${#candidates} )); then
+		compadd -Q -a candidates
+	else
+		_files
+	fi
+}
+compdef _devj devj
+`,
+	"fish": `# fish completion for devj; load it with: devj completion fish | source
+function __devj_complete
+	set -l candidates (devj __complete -- (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)
+	if test (count $candidates) -gt 0
+		printf '%s\n' $candidates
+	else
+		__fish_complete_path (commandline -ct)
+	end
+end
+complete -c devj -f -a '(__devj_complete)'
+`,
+}
+
+func init() {
+	// __complete goes through the commands, so it can only join them once they are made.
+	commands = append(commands, &command{name: "__complete",
+		summary: "list what can come in place of the last word of a devj command line",
+		args:    "[--] <word>...", maxArgs: -1, noJournal: true, hidden: true, run: CompleteCommand})
+}
+
+// CompletionCommand prints the completion script for a shell.
+func CompletionCommand(conf *Config, args []string) error {
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		return usagef(`unknown shell %q, use "bash", "zsh" or "fish"`, args[0])
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// CompleteCommand prints what can come in place of the last of args, one per line.
+// args are the words of a devj command line being completed, after devj itself, ending with
+// the word being completed. conf is nil outside a journal, where only commands and flags complete.
+func CompleteCommand(conf *Config, args []string) error {
+	for _, c := range complete(conf, args) {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+// completer completes the values of flags and arguments from a journal.
+type completer struct {
+	// conf is nil outside a journal, where no values complete.
+	conf *Config
+	// root is the journal root, which the working directory is once run has opened the journal.
+	root string
+}
+
+// complete lists what can come in place of the last of words, and starts with it.
+func complete(conf *Config, words []string) []string {
+	c := completer{conf: conf, root: "."}
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, words := words[len(words)-1], words[:len(words)-1]
+
+	// A -root among the global flags names the journal to complete from.
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		root := ""
+		switch {
+		case strings.TrimLeft(words[0], "-") == "root":
+			if len(words) == 1 {
+				// A folder, which the shell completes.
+				return nil
+			}
+			root, words = words[1], words[2:]
+		case strings.HasPrefix(strings.TrimLeft(words[0], "-"), "root="):
+			root, words = strings.SplitN(words[0], "=", 2)[1], words[1:]
+		default:
+			words = words[1:]
+			continue
+		}
+		c = completer{}
+		if dir, err := JournalRoot(root); err == nil {
+			c.conf, _ = ReadConfig(filesystem.OS(dir))
+			c.root = dir
+		}
+	}
+
+	if len(words) == 0 {
+		if strings.HasPrefix(cur, "-") {
+			return matching(cur, []string{"-root"})
+		}
+		return matching(cur, append(commandNames(commands), "help"))
+	}
+	if words[0] == "help" {
+		cmds := commands
+		for _, w := range words[1:] {
+			cmd := lookupCommand(cmds, w)
+			if cmd == nil {
+				return nil
+			}
+			cmds = cmd.subcommands
+		}
+		return matching(cur, commandNames(cmds))
+	}
+
+	cmd, name, args := findCommand(words)
+	if cmd == nil || cmd.hidden {
+		return nil
+	}
+	if cmd.subcommands != nil {
+		if len(args) > 0 {
+			return nil
+		}
+		return matching(cur, commandNames(cmd.subcommands))
+	}
+
+	fs := flag.NewFlagSet("devj "+name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if cmd.flags != nil {
+		if c.conf != nil {
+			cmd.flags(fs, c.conf)
+		} else {
+			cmd.flags(fs, defaultConfig())
+		}
+	}
+
+	// Go over the words before the one being completed, as parseFlags would, to find whether it is
+	// the value of a flag, or else which argument it is.
+	var value *flag.Flag
+	position, flagsDone := 0, false
+	for _, w := range args {
+		switch {
+		case value != nil:
+			value = nil
+		case flagsDone || !strings.HasPrefix(w, "-") || w == "-":
+			position++
+			flagsDone = flagsDone || !cmd.interspersed
+		case w == "--":
+			flagsDone = true
+		default:
+			if f := fs.Lookup(strings.TrimLeft(w, "-")); f != nil && !isBoolFlag(f) {
+				value = f
+			}
+		}
+	}
+	switch {
+	case value != nil:
+		return matching(cur, c.flagValues(value.Name, cur))
+	case !flagsDone && strings.HasPrefix(cur, "-"):
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) {
+			names = append(names, "-"+f.Name)
+		})
+		return matching(cur, names)
+	}
+	return matching(cur, c.argValues(name, position))
+}
+
+// flagValues lists the values of the flag named name, for the journal of c.
+// cur is the value being completed, which for lists is what comes before the value's last item.
+func (c completer) flagValues(name, cur string) []string {
+	switch name {
+	case "format":
+		return []string{"markdown", "plain", "json"}
+	case "group-by":
+		return []string{"tag", "project", "none"}
+	case "section":
+		return c.sectionTitles()
+	case "sections":
+		// The last of the comma separated sections is completed.
+		before := cur[:strings.LastIndex(cur, ",")+1]
+		var values []string
+		for _, title := range c.sectionTitles() {
+			values = append(values, before+title)
+		}
+		return values
+	case "from", "to":
+		return c.dateValues(entry.Date.String)
+	case "week":
+		return c.dateValues(entry.Date.Week)
+	case "month":
+		return c.dateValues(entry.Date.MonthString)
+	}
+	return nil
+}
+
+// argValues lists the values of the argument at position of the command named name,
+// for the journal of c.
+func (c completer) argValues(name string, position int) []string {
+	switch {
+	case name == "completion" && position == 0:
+		return []string{"bash", "zsh", "fish"}
+	case name == "section merge", (name == "section rename" || name == "section split") && position == 0:
+		return c.sectionTitles()
+	case name == "cal" && position == 0:
+		return c.dateValues(entry.Date.MonthString)
+	case name == "relayout" && position == 0:
+		return []string{filesystem.DefaultLayout, "{yyyy}/{mm}/{dd}.md", "{yyyy}/{mm}/{date}.md"}
+	case (name == "attachments rename" || name == "attachments remove") && position == 0:
+		return c.attachmentPaths()
+	case name == "sync" && position == 0:
+		return c.remotes()
+	}
+	return nil
+}
+
+// sectionTitles lists the section titles of the latest entries, without repeats, for the journal of c.
+// Entries are read as they are on disk, so the titles of wholly encrypted entries are left out
+// rather than asked a passphrase for.
+func (c completer) sectionTitles() []string {
+	if c.conf == nil {
+		return nil
+	}
+	dates, err := c.conf.Layout.List(c.conf.FS)
+	if err != nil {
+		return nil
+	}
+	if len(dates) > recentEntries {
+		dates = dates[len(dates)-recentEntries:]
+	}
+	var titles []string
+	seen := map[string]bool{}
+	for _, date := range dates {
+		raw, err := c.conf.FS.ReadFile(c.conf.Layout.Path(date))
+		if err != nil {
+			continue
+		}
+		e, err := entry.Import(string(raw))
+		if err != nil {
+			continue
+		}
+		for _, s := range e.Sections {
+			if key := strings.ToLower(s.Title); !seen[key] {
+				seen[key] = true
+				titles = append(titles, s.Title)
+			}
+		}
+	}
+	return titles
+}
+
+// dateValues lists the dates of the entries of the journal of c, written by format, without repeats.
+func (c completer) dateValues(format func(entry.Date) string) []string {
+	if c.conf == nil {
+		return nil
+	}
+	dates, err := c.conf.Layout.List(c.conf.FS)
+	if err != nil {
+		return nil
+	}
+	var values []string
+	for _, date := range dates {
+		if v := format(date); len(values) == 0 || values[len(values)-1] != v {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// attachmentPaths lists the attachments of every entry of the journal of c, as findAttachment takes them.
+func (c completer) attachmentPaths() []string {
+	if c.conf == nil {
+		return nil
+	}
+	dates, err := c.conf.Layout.List(c.conf.FS)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, date := range dates {
+		names, err := c.conf.attachments(date)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			paths = append(paths, path.Join(c.conf.Layout.Dir(date), name))
+		}
+	}
+	return paths
+}
+
+// remotes lists the git remotes of the journal of c, along with the configured one.
+func (c completer) remotes() []string {
+	if c.conf == nil {
+		return nil
+	}
+	remotes, _ := GitRemotes(c.root)
+	return append(remotes, c.conf.Git.Remote)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// commandNames lists the names of the commands that are not hidden.
+func commandNames(cmds []*command) []string {
+	var names []string
+	for _, c := range cmds {
+		if !c.hidden {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+// matching returns the candidates starting with prefix, sorted and without repeats or empty ones.
+func matching(prefix string, candidates []string) []string {
+	var found []string
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if c != "" && !seen[c] && strings.HasPrefix(c, prefix) {
+			seen[c] = true
+			found = append(found, c)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+func TestComplete(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 3)
+	fsys.WriteFile("2019-01-03/2019-01-03.md", []byte("# Do\n\nthings\n\n# Blockers\n\nnone\n"))
+	conf := defaultConfig()
+	conf.FS = fsys
+	conf.Git.Remote = "upstream-journal"
+
+	tests := map[string]struct {
+		Conf     *Config
+		Words    []string
+		Expected []string
+	}{
+		"commands":             {Conf: conf, Words: []string{"s"}, Expected: []string{"search", "section", "standup", "stats", "sync"}},
+		"hidden command":       {Conf: conf, Words: []string{"__"}, Expected: nil},
+		"global flags":         {Conf: conf, Words: []string{"-"}, Expected: []string{"-root"}},
+		"after -root":          {Conf: conf, Words: []string{"-root", "journal", "ca"}, Expected: []string{"cache", "cal"}},
+		"subcommands":          {Conf: conf, Words: []string{"cache", ""}, Expected: []string{"rebuild", "verify"}},
+		"help":                 {Conf: conf, Words: []string{"help", "section", "s"}, Expected: []string{"split"}},
+		"flags":                {Conf: conf, Words: []string{"report", "-s"}, Expected: []string{"-save", "-sections"}},
+		"flag value":           {Conf: conf, Words: []string{"standup", "-format", ""}, Expected: []string{"json", "markdown", "plain"}},
+		"dates":                {Conf: conf, Words: []string{"section", "rename", "-from", "2019-01-0"}, Expected: []string{"2019-01-01", "2019-01-02", "2019-01-03"}},
+		"weeks":                {Conf: conf, Words: []string{"report", "-week", ""}, Expected: []string{"2019-W01"}},
+		"months":               {Conf: conf, Words: []string{"cal", ""}, Expected: []string{"2019-01"}},
+		"sections":             {Conf: conf, Words: []string{"section", "merge", "Do", ""}, Expected: []string{"Blockers", "Do", "Learn"}},
+		"section list":         {Conf: conf, Words: []string{"report", "-sections", "Do,L"}, Expected: []string{"Do,Learn"}},
+		"after bool flag":      {Conf: conf, Words: []string{"section", "split", "-apply", "L"}, Expected: []string{"Learn"}},
+		"second argument":      {Conf: conf, Words: []string{"section", "rename", "Do", "L"}, Expected: nil},
+		"interspersed flags":   {Conf: conf, Words: []string{"attach", "notes.txt", "-section", "B"}, Expected: []string{"Blockers"}},
+		"attachments":          {Conf: conf, Words: []string{"attachments", "remove", "2019-01-02/"}, Expected: []string{"2019-01-02/notes-1.txt"}},
+		"remotes":              {Conf: conf, Words: []string{"sync", "upstream-"}, Expected: []string{"upstream-journal"}},
+		"shells":               {Conf: nil, Words: []string{"completion", ""}, Expected: []string{"bash", "fish", "zsh"}},
+		"no journal":           {Conf: nil, Words: []string{"cal", ""}, Expected: nil},
+		"no journal, flags":    {Conf: nil, Words: []string{"cal", "-"}, Expected: []string{"-i"}},
+		"files for arguments":  {Conf: conf, Words: []string{"restore", ""}, Expected: nil},
+		"unknown command":      {Conf: conf, Words: []string{"nosuch", ""}, Expected: nil},
+		"nothing typed at all": {Conf: conf, Words: nil, Expected: matching("", append(commandNames(commands), "help"))},
+	}
+	for name, test := range tests {
+		actual := complete(test.Conf, test.Words)
+		if !reflect.DeepEqual(actual, test.Expected) {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, actual, test.Expected, name)
+		}
+	}
+}
@@ -3,38 +3,95 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"path"
 
+	"github.com/ifo/dev.journal/crypt"
 	"github.com/ifo/dev.journal/entry"
 	"github.com/ifo/dev.journal/filesystem"
 )
 
+const configFile = ".devj"
+
 type Config struct {
 	PublicSections map[string]struct{} `json:"public_sections"`
 	EditorCommand  string              `json:"editor_command"`
+	Report         ReportConfig        `json:"report"`
+	Standup        StandupConfig       `json:"standup"`
+	// Layout is where entries are kept in the journal. See filesystem.Layout for the patterns it can use.
+	Layout filesystem.Layout `json:"layout"`
+	Git    GitConfig         `json:"git"`
+	Backup BackupConfig      `json:"backup"`
+	// Attachments limits the attachments that are exported.
+	Attachments entry.FileFilter `json:"attachments"`
+	// Timezone and DayStartsAt decide which day it is, as the name of a time zone such as "Europe/Berlin"
+	// and a time like "04:00" that days start at, so work past midnight counts as the day before.
+	// They default to the local time zone and midnight.
+	Timezone    string `json:"timezone"`
+	DayStartsAt string `json:"day_starts_at"`
+	// Clock is what Timezone and DayStartsAt describe.
+	Clock entry.Clock `json:"-"`
+	// Encryption is off unless its mode is set. See EncryptionConfig.
+	Encryption EncryptionConfig `json:"encryption"`
+
+	// FS is the journal the config was read from.
+	FS filesystem.FS `json:"-"`
+	// key is the journal's encryption key, once journalKey has derived it.
+	key *crypt.Key
+}
+
+// ReportConfig controls what goes into the reports made by devj report.
+type ReportConfig struct {
+	// Sections are the titles of the sections to gather, in the order they appear in the report.
+	Sections []string `json:"sections"`
+	// GroupBy is how the bullets of each section are grouped: by "tag", by "project" or "none".
+	GroupBy string `json:"group_by"`
+}
+
+// StandupConfig names the sections devj standup reads for each part of the standup.
+type StandupConfig struct {
+	// Yesterday sections of the previous working day's entry give its bullets and completed tasks.
+	Yesterday []string `json:"yesterday"`
+	// Today sections of today's entry give its open tasks and any bullets that are new today.
+	Today []string `json:"today"`
+	// Blockers sections of today's entry give every item in them.
+	// Items tagged #blocked in the other sections are blockers too.
+	Blockers []string `json:"blockers"`
 }
 
 type lenientConfig struct {
 	PublicSections map[string]interface{} `json:"public_sections"`
 	EditorCommand  string                 `json:"editor_command"`
+	Report         ReportConfig           `json:"report"`
+	Standup        StandupConfig          `json:"standup"`
+	Layout         string                 `json:"layout"`
+	Git            GitConfig              `json:"git"`
+	Backup         BackupConfig           `json:"backup"`
+	Attachments    entry.FileFilter       `json:"attachments"`
+	Timezone       string                 `json:"timezone"`
+	DayStartsAt    string                 `json:"day_starts_at"`
+	Encryption     EncryptionConfig       `json:"encryption"`
 }
 
-func ReadConfig() (*Config, error) {
-	bts, err := ioutil.ReadFile(".devj")
+// ReadConfig reads the config file of the journal in fsys.
+func ReadConfig(fsys filesystem.FS) (*Config, error) {
+	bts, err := fsys.ReadFile(configFile)
 	if err != nil {
 		return nil, err
 	}
 
 	var conf *Config
 	err = json.Unmarshal(bts, &conf)
+	if err != nil {
+		return nil, err
+	}
+	conf.FS = fsys
 
 	// Let's set some defaults.
 	if conf.EditorCommand == "" {
 		conf.EditorCommand = "vim"
 	}
 
-	return conf, err
+	return conf, nil
 }
 
 func (c *Config) UnmarshalJSON(buf []byte) error {
@@ -51,41 +108,124 @@ func (c *Config) UnmarshalJSON(buf []byte) error {
 	for k, _ := range lc.PublicSections {
 		c.PublicSections[k] = struct{}{}
 	}
+	c.Report = lc.Report
+	if len(c.Report.Sections) == 0 {
+		for _, s := range entry.Default.Sections {
+			c.Report.Sections = append(c.Report.Sections, s.Title)
+		}
+	}
+	if c.Report.GroupBy == "" {
+		c.Report.GroupBy = "tag"
+	}
+	c.Standup = lc.Standup
+	if len(c.Standup.Yesterday) == 0 {
+		c.Standup.Yesterday = []string{"Do"}
+	}
+	if len(c.Standup.Today) == 0 {
+		c.Standup.Today = []string{"Do"}
+	}
+	if len(c.Standup.Blockers) == 0 {
+		c.Standup.Blockers = []string{"Blockers"}
+	}
+	c.Git = lc.Git
+	c.Backup = lc.Backup
+	if c.Backup.Dir == "" {
+		c.Backup.Dir = ".devj-archives"
+	}
+	c.Attachments = lc.Attachments
+	for _, pattern := range c.Attachments.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad attachment ignore pattern %q: %v", pattern, err)
+		}
+	}
+	c.Timezone, c.DayStartsAt = lc.Timezone, lc.DayStartsAt
+	if c.Clock, err = entry.ParseClock(c.Timezone, c.DayStartsAt); err != nil {
+		return err
+	}
+	c.Encryption = lc.Encryption
+	switch c.Encryption.Mode {
+	case "", encryptPrivate, encryptEntry:
+	default:
+		return fmt.Errorf("unknown encryption mode %q, use %q or %q", c.Encryption.Mode, encryptPrivate, encryptEntry)
+	}
+	c.Layout = filesystem.Layout{}
+	if lc.Layout != "" {
+		if c.Layout, err = filesystem.ParseLayout(lc.Layout); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (c *Config) ImportJournal(basePath string) (*entry.Journal, error) {
-	entries, err := filesystem.ListDirs(basePath)
-	if err != nil {
-		return nil, err
-	}
-	out := &entry.Journal{Entries: map[entry.EntryName]entry.Entry{}}
-	for _, date := range entries {
-		entryDir := filepath.Join(basePath, date)
-		rawEntry, err := filesystem.ReadFile(filepath.Join(entryDir, fmt.Sprintf("%s.md", date)))
-		if err != nil {
-			return nil, err
-		}
+// Today is the date of the entry being written now, as the config's Clock tells it.
+func (c *Config) Today() entry.Date {
+	return c.Clock.Today()
+}
 
-		e, err := entry.ImportPublic(string(rawEntry), c.PublicSections)
-		if err != nil {
-			return nil, err
+// WritePublicSections replaces public_sections in the config file at path in fsys with sections.
+// The rest of the file, and the values of sections that were already public, are kept.
+func WritePublicSections(fsys filesystem.FS, path string, sections map[string]struct{}) error {
+	return updateConfig(fsys, path, func(raw map[string]json.RawMessage) error {
+		var oldSections map[string]json.RawMessage
+		if ps, ok := raw["public_sections"]; ok {
+			if err := json.Unmarshal(ps, &oldSections); err != nil {
+				return err
+			}
 		}
 
-		files, err := filesystem.ListFiles(entryDir)
-		if err != nil {
-			return nil, err
-		}
-		e.FileNames = map[string]struct{}{}
-		for _, name := range files {
-			e.FileNames[name] = struct{}{}
+		newSections := map[string]json.RawMessage{}
+		for k := range sections {
+			if v, ok := oldSections[k]; ok {
+				newSections[k] = v
+			} else {
+				newSections[k] = json.RawMessage("true")
+			}
 		}
+		var err error
+		raw["public_sections"], err = json.Marshal(newSections)
+		return err
+	})
+}
 
-		err = e.ImportFiles(c.PublicSections, entryDir, filesystem.ReadFile)
-		if err != nil {
-			return nil, err
-		}
-		out.Entries[entry.EntryName(date)] = e
+// WriteLayout replaces the layout in the config file at path in fsys, keeping the rest of the file.
+func WriteLayout(fsys filesystem.FS, path string, layout filesystem.Layout) error {
+	return updateConfig(fsys, path, func(raw map[string]json.RawMessage) error {
+		var err error
+		raw["layout"], err = json.Marshal(layout)
+		return err
+	})
+}
+
+// WriteEncryption replaces the encryption settings in the config file at path in fsys, keeping the rest of the file.
+func WriteEncryption(fsys filesystem.FS, path string, enc EncryptionConfig) error {
+	return updateConfig(fsys, path, func(raw map[string]json.RawMessage) error {
+		var err error
+		raw["encryption"], err = json.Marshal(enc)
+		return err
+	})
+}
+
+// updateConfig rewrites the config file at path in fsys after update changes its top level values.
+func updateConfig(fsys filesystem.FS, path string, update func(raw map[string]json.RawMessage) error) error {
+	bts, err := fsys.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(bts, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		raw = map[string]json.RawMessage{}
+	}
+	if err := update(raw); err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
 	}
-	return out, nil
+	return fsys.WriteFile(path, append(out, '\n'))
 }
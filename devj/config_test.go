@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+func TestWritePublicSections(t *testing.T) {
+	tests := map[string]struct {
+		Config   string
+		Sections map[string]struct{}
+		Out      string
+	}{
+		"replaced": {
+			Config:   `{"public_sections": {"do": true, "learn": true}}`,
+			Sections: map[string]struct{}{"learn": {}, "tasks": {}},
+			Out:      "{\n  \"public_sections\": {\n    \"learn\": true,\n    \"tasks\": true\n  }\n}\n"},
+		"values kept": {
+			Config:   `{"public_sections": {"do": "yes"}}`,
+			Sections: map[string]struct{}{"do": {}},
+			Out:      "{\n  \"public_sections\": {\n    \"do\": \"yes\"\n  }\n}\n"},
+		"rest kept": {
+			Config:   `{"editor_command": "nano", "layout": "{date}.md"}`,
+			Sections: map[string]struct{}{},
+			Out:      "{\n  \"editor_command\": \"nano\",\n  \"layout\": \"{date}.md\",\n  \"public_sections\": {}\n}\n"},
+	}
+
+	for id, test := range tests {
+		fsys := fstest.NewMemFS()
+		fsys.WriteFile(configFile, []byte(test.Config))
+		if err := WritePublicSections(fsys, configFile, test.Sections); err != nil {
+			t.Errorf("Case %q: %v", id, err)
+			continue
+		}
+		if out, _ := fsys.ReadFile(configFile); string(out) != test.Out {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, string(out), test.Out, id)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ifo/dev.journal/crypt"
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// passphraseEnv holds the passphrase of an encrypted journal, so devj does not ask for it.
+const passphraseEnv = "DEVJ_PASSPHRASE"
+
+// checkText is sealed with the journal's key to tell whether a passphrase is the right one.
+const checkText = "devj"
+
+// The modes of EncryptionConfig.
+const (
+	encryptPrivate = "private"
+	encryptEntry   = "entry"
+)
+
+// EncryptionConfig controls keeping a journal's entries encrypted with a key derived from a passphrase.
+type EncryptionConfig struct {
+	// Mode is what is encrypted: "private" for the bodies of the sections not in public_sections,
+	// "entry" for whole entries, or "" for nothing.
+	// Exports only read public sections, so they work without the passphrase, but leave out
+	// entries encrypted as a whole.
+	Mode string `json:"mode"`
+	// Salt and Check are written the first time a passphrase is given: the salt of the key,
+	// and text sealed with the key that later passphrases are checked against.
+	Salt  string `json:"salt,omitempty"`
+	Check string `json:"check,omitempty"`
+}
+
+// EncryptCommand encrypts every entry of the journal as its config asks, and rebuilds the cache
+// so that it no longer holds the public sections of entries encrypted as a whole.
+func EncryptCommand(conf *Config, args []string) error {
+	if conf.Encryption.Mode == "" {
+		return fmt.Errorf("set encryption.mode in %s to %q or %q first", configFile, encryptPrivate, encryptEntry)
+	}
+
+	unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	key, err := conf.journalKey()
+	if err != nil {
+		return err
+	}
+	n, err := conf.encryptJournal(key)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("encrypted %d entries\n", n)
+	if n > 0 {
+		fmt.Println("backups and git history made before now still hold them in plain text")
+	}
+	return nil
+}
+
+// encryptJournal seals every entry that is not sealed as the config asks, and returns how many it changed.
+func (c *Config) encryptJournal(key *crypt.Key) (int, error) {
+	dates, err := c.Layout.List(c.FS)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, date := range dates {
+		name := c.Layout.Path(date)
+		raw, err := c.FS.ReadFile(name)
+		if err != nil {
+			return changed, err
+		}
+		plain, err := unsealEntry(key, string(raw))
+		if err != nil {
+			return changed, fmt.Errorf("%s: %v", name, err)
+		}
+		sealed, err := c.sealEntry(key, plain, string(raw))
+		if err != nil {
+			return changed, fmt.Errorf("%s: %v", name, err)
+		}
+		if sealed == string(raw) {
+			continue
+		}
+		if err := c.FS.WriteFile(name, []byte(sealed)); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+
+	ch := c.openCache(c.FS)
+	ch.Reset()
+	for _, date := range dates {
+		if _, err := ch.Lookup(c.Layout.Path(date), c.Layout.Dir(date)); err != nil {
+			return changed, fmt.Errorf("%s: %v", c.Layout.Path(date), err)
+		}
+	}
+	return changed, ch.Save()
+}
+
+// openSealedEntry is openEntry for an encrypted journal. The entry is decrypted into a temporary file
+// only its owner can read, which is encrypted back into the journal once the editor exits.
+func openSealedEntry(conf *Config, date entry.Date) error {
+	key, err := conf.journalKey()
+	if err != nil {
+		return err
+	}
+	name := conf.Layout.Path(date)
+	raw, err := conf.FS.ReadFile(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	plain, err := unsealEntry(key, string(raw))
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	// TempFile makes the file readable by its owner alone.
+	tmp, err := ioutil.TempFile("", "devj-*"+filepath.Ext(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(plain)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(conf.EditorCommand, tmp.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", conf.EditorCommand, err)
+	}
+	edited, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+
+	unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// An unchanged entry is still written if it is not yet encrypted as the config asks.
+	sealed, err := conf.sealEntry(key, string(edited), string(raw))
+	if err != nil {
+		return err
+	}
+	if sealed == string(raw) {
+		return nil
+	}
+	if err := conf.FS.WriteFile(name, []byte(sealed)); err != nil {
+		return err
+	}
+	return conf.autoCommit(date, importOrEmpty(plain), importOrEmpty(string(edited)))
+}
+
+// sealEntry encrypts the entry plain as the config asks. previous is the file plain replaces:
+// parts of plain it already holds encrypted keep their old encrypted text, so that they do not
+// show up as changed in the journal's history.
+func (c *Config) sealEntry(key *crypt.Key, plain, previous string) (string, error) {
+	if c.Encryption.Mode == encryptEntry {
+		if old, err := key.Open(previous); err == nil && string(old) == plain {
+			return previous, nil
+		}
+		sealed, err := key.Seal([]byte(plain))
+		if err != nil {
+			return "", err
+		}
+		return sealed + "\n", nil
+	}
+
+	e, err := entry.Import(plain)
+	if err != nil {
+		return "", err
+	}
+	oldBodies := map[string]string{}
+	if old, err := entry.Import(previous); err == nil {
+		for _, s := range old.Sections {
+			oldBodies[s.Title] = s.Body
+		}
+	}
+	for i, s := range e.Sections {
+		if _, ok := c.PublicSections[strings.ToLower(s.Title)]; ok || s.Body == "" || crypt.IsSealed(s.Body) {
+			continue
+		}
+		if old, err := key.Open(oldBodies[s.Title]); err == nil && string(old) == s.Body {
+			e.Sections[i].Body = oldBodies[s.Title]
+			continue
+		}
+		sealed, err := key.Seal([]byte(s.Body))
+		if err != nil {
+			return "", err
+		}
+		e.Sections[i].Body = sealed
+	}
+	return e.Export(), nil
+}
+
+// unsealEntry decrypts an entry encrypted as a whole, or the encrypted sections of one.
+// Entries with nothing encrypted are returned as they are.
+func unsealEntry(key *crypt.Key, raw string) (string, error) {
+	if crypt.IsSealed(raw) {
+		plain, err := key.Open(raw)
+		return string(plain), err
+	}
+
+	e, err := entry.Import(raw)
+	if err != nil {
+		return raw, nil
+	}
+	sealed := false
+	for i, s := range e.Sections {
+		if !crypt.IsSealed(s.Body) {
+			continue
+		}
+		plain, err := key.Open(s.Body)
+		if err != nil {
+			return "", fmt.Errorf("section %s: %v", s.Title, err)
+		}
+		e.Sections[i].Body = string(plain)
+		sealed = true
+	}
+	if !sealed {
+		return raw, nil
+	}
+	return e.Export(), nil
+}
+
+// journalKey derives the journal's key from the passphrase in $DEVJ_PASSPHRASE, or asked for on the terminal.
+// The first passphrase given becomes the journal's, and its salt and check are written to the config.
+func (c *Config) journalKey() (*crypt.Key, error) {
+	if c.key != nil {
+		return c.key, nil
+	}
+	pass := os.Getenv(passphraseEnv)
+	if pass == "" {
+		var err error
+		if pass, err = readPassphrase("passphrase: "); err != nil {
+			return nil, err
+		}
+		// A mistyped first passphrase would lock the owner out of their journal.
+		if c.Encryption.Salt == "" {
+			again, err := readPassphrase("passphrase again: ")
+			if err != nil {
+				return nil, err
+			}
+			if again != pass {
+				return nil, fmt.Errorf("the passphrases differ")
+			}
+		}
+	}
+
+	if c.Encryption.Salt == "" {
+		salt, err := crypt.NewSalt()
+		if err != nil {
+			return nil, err
+		}
+		key, err := crypt.DeriveKey(pass, salt)
+		if err != nil {
+			return nil, err
+		}
+		check, err := key.Seal([]byte(checkText))
+		if err != nil {
+			return nil, err
+		}
+		c.Encryption.Salt = base64.StdEncoding.EncodeToString(salt)
+		c.Encryption.Check = check
+		c.key = key
+		return key, WriteEncryption(c.FS, configFile, c.Encryption)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(c.Encryption.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("bad encryption salt in %s: %v", configFile, err)
+	}
+	key, err := crypt.DeriveKey(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+	if check, err := key.Open(c.Encryption.Check); err != nil || string(check) != checkText {
+		return nil, fmt.Errorf("wrong passphrase")
+	}
+	c.key = key
+	return key, nil
+}
+
+// readPassphrase asks for a passphrase on the terminal, without echoing it.
+func readPassphrase(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for the passphrase on; set $%s", passphraseEnv)
+	}
+	defer tty.Close()
+
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = tty
+		return cmd.Run()
+	}
+	fmt.Fprint(tty, prompt)
+	if err := stty("-echo"); err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	stty("echo")
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// decrypt returns the entry raw with everything encrypted in it decrypted.
+// The journal's key is only asked for if something is encrypted.
+func (c *Config) decrypt(raw string) (string, error) {
+	if !crypt.IsSealed(raw) {
+		e, err := entry.Import(raw)
+		if err != nil {
+			// Nothing can be decrypted, so the error is left to whoever imports raw.
+			return raw, nil
+		}
+		sealed := false
+		for _, s := range e.Sections {
+			sealed = sealed || s.Sealed()
+		}
+		if !sealed {
+			return raw, nil
+		}
+	}
+	if c.Encryption.Salt == "" {
+		return "", fmt.Errorf("the entry is encrypted, but %s has no encryption salt to make its key with", configFile)
+	}
+	key, err := c.journalKey()
+	if err != nil {
+		return "", err
+	}
+	return unsealEntry(key, raw)
+}
+
+// readEntry reads the entry for date, decrypting what is encrypted in it,
+// along with its file as it is stored, which writeEntry needs.
+func (c *Config) readEntry(date entry.Date) (entry.Entry, string, error) {
+	raw, err := c.FS.ReadFile(c.Layout.Path(date))
+	if err != nil {
+		return entry.Entry{}, "", err
+	}
+	plain, err := c.decrypt(string(raw))
+	if err != nil {
+		return entry.Entry{}, "", fmt.Errorf("%s: %v", c.Layout.Path(date), err)
+	}
+	e, err := entry.Import(plain)
+	if err != nil {
+		return entry.Entry{}, "", fmt.Errorf("%s: %v", c.Layout.Path(date), err)
+	}
+	return e, string(raw), nil
+}
+
+// writeEntry writes e as the entry for date, encrypting it if the journal is encrypted.
+// raw is the file e replaces, as readEntry returned it.
+func (c *Config) writeEntry(date entry.Date, e entry.Entry, raw string) error {
+	out := e.Export()
+	if c.Encryption.Mode != "" {
+		key, err := c.journalKey()
+		if err != nil {
+			return err
+		}
+		if out, err = c.sealEntry(key, out, raw); err != nil {
+			return err
+		}
+	}
+	return c.FS.WriteFile(c.Layout.Path(date), []byte(out))
+}
+
+// importOrEmpty parses the entry s, counting an entry that cannot be parsed as empty.
+func importOrEmpty(s string) *entry.Entry {
+	e, err := entry.Import(s)
+	if err != nil {
+		return &entry.Entry{}
+	}
+	return &e
+}
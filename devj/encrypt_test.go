@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ifo/dev.journal/cache"
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+	"github.com/ifo/dev.journal/search"
+)
+
+func TestConfig_EncryptJournal(t *testing.T) {
+	for _, mode := range []string{encryptPrivate, encryptEntry} {
+		fsys := fstest.NewMemFS()
+		writeJournal(t, fsys, 3)
+		fsys.WriteFile(configFile, []byte(fmt.Sprintf(`{"public_sections": {"learn": true}, "encryption": {"mode": %q}}`, mode)))
+		plain, _ := fsys.ReadFile("2019-01-01/2019-01-01.md")
+
+		t.Setenv(passphraseEnv, "correct horse")
+		conf, err := ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := conf.journalKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := conf.encryptJournal(key); n != 3 || err != nil {
+			t.Fatalf("%s: encrypted %d entries with error %v", mode, n, err)
+		}
+		if n, err := conf.encryptJournal(key); n != 0 || err != nil {
+			t.Errorf("%s: encrypted %d entries again with error %v", mode, n, err)
+		}
+
+		// Nothing in the journal, the cache included, holds the private sections in plain text.
+		for _, name := range fsys.Names() {
+			if b, _ := fsys.ReadFile(name); strings.Contains(string(b), "things") {
+				t.Errorf("%s: %s holds a private section in plain text", mode, name)
+			}
+		}
+		raw, _ := fsys.ReadFile("2019-01-01/2019-01-01.md")
+		if unsealed, err := unsealEntry(key, string(raw)); unsealed != string(plain) || err != nil {
+			t.Errorf(`Actual: "%v", %v Expected: "%v" Case: %q`, unsealed, err, string(plain), mode)
+		}
+		if resealed, err := conf.sealEntry(key, string(plain), string(raw)); resealed != string(raw) || err != nil {
+			t.Errorf("%s: sealing an unchanged entry changed it to %q, %v", mode, resealed, err)
+		}
+
+		// Public sections are read without the key, unless the whole entry is encrypted.
+		jrn, err := conf.ImportJournal(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := jrn.Entries["2019-01-01"]
+		switch {
+		case mode == encryptPrivate && (len(e.Sections) != 1 || e.Sections[0].Body != "see notes-0.txt" || len(e.PublicFiles) != 1):
+			t.Errorf("%s: imported %+v, expected the public Learn section and its file", mode, e)
+		case mode == encryptEntry && len(jrn.Entries) != 0:
+			t.Errorf("%s: imported %+v from encrypted entries", mode, jrn.Entries)
+		}
+
+		// The salt and check were saved, so another passphrase is refused.
+		t.Setenv(passphraseEnv, "battery staple")
+		conf, err = ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conf.journalKey(); err == nil || err.Error() != "wrong passphrase" {
+			t.Errorf("%s: a different passphrase gave error %v", mode, err)
+		}
+	}
+}
+
+func TestConfig_LoadJournal_Encrypted(t *testing.T) {
+	today, _ := entry.ParseDate("2019-01-03")
+	for _, mode := range []string{encryptPrivate, encryptEntry} {
+		fsys := fstest.NewMemFS()
+		writeJournal(t, fsys, 3)
+		fsys.WriteFile(configFile, []byte(fmt.Sprintf(`{"public_sections": {"learn": true}, "encryption": {"mode": %q}}`, mode)))
+		t.Setenv(passphraseEnv, "correct horse")
+		conf, err := ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := conf.LoadJournal(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := conf.journalKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conf.encryptJournal(key); err != nil {
+			t.Fatal(err)
+		}
+
+		// Encrypted entries are decrypted, so search and stats see them as they were.
+		conf, err = ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jrn, err := conf.LoadJournal(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := search.Build(jrn).Search("things")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 3 {
+			t.Errorf("%s: found %d results for a word in every entry, expected 3", mode, len(results))
+		}
+		if results, _ := search.Build(jrn).Search("BEGIN"); len(results) != 0 {
+			t.Errorf("%s: found encrypted text: %+v", mode, results)
+		}
+		// Loading through the cache leaves no private section in it in plain text.
+		if b, _ := fsys.ReadFile(cache.FileName); strings.Contains(string(b), "things") {
+			t.Errorf("%s: the cache holds a private section in plain text", mode)
+		}
+		actual, expected := BuildStats(jrn, today, 1, 5), BuildStats(plain, today, 1, 5)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf(`Actual: "%+v" Expected: "%+v" Case: %q`, actual, expected, mode)
+		}
+
+		// Without the passphrase, encrypted entries cannot be loaded, rather than loading as empty.
+		t.Setenv(passphraseEnv, "battery staple")
+		conf, err = ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conf.LoadJournal(fsys); err == nil {
+			t.Errorf("%s: loaded an encrypted journal with the wrong passphrase", mode)
+		}
+	}
+}
+
+func TestConfig_PublicReads_Encrypted(t *testing.T) {
+	date, _ := entry.ParseDate("2019-01-01")
+	for _, mode := range []string{encryptPrivate, encryptEntry} {
+		fsys := fstest.NewMemFS()
+		writeJournal(t, fsys, 3)
+		fsys.WriteFile(configFile, []byte(fmt.Sprintf(`{"public_sections": {"learn": true}, "encryption": {"mode": %q}}`, mode)))
+		t.Setenv(passphraseEnv, "correct horse")
+		conf, err := ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := conf.journalKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conf.encryptJournal(key); err != nil {
+			t.Fatal(err)
+		}
+
+		// A new entry is encrypted as soon as it is made, even if it is never edited.
+		if err := MakeNewEntry(conf); err != nil {
+			t.Fatal(err)
+		}
+		if raw, _ := fsys.ReadFile(conf.Layout.Path(conf.Today())); strings.Contains(string(raw), "things") {
+			t.Errorf("%s: the new entry holds a private section in plain text:\n%s", mode, raw)
+		}
+
+		// Public sections are read without the key, unless the whole entry is encrypted.
+		t.Setenv(passphraseEnv, "battery staple")
+		conf, err = ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := readPublicEntry(conf, date)
+		_, importErr := conf.ImportJournalContext(context.Background(), fsys, ImportOptions{Unseal: true})
+		switch {
+		case mode == encryptPrivate && (err != nil || len(e.Sections) != 1 || e.Sections[0].Title != "Learn"):
+			t.Errorf("%s: read %+v with error %v, expected the public Learn section", mode, e, err)
+		case mode == encryptPrivate && importErr != nil:
+			t.Errorf("%s: imported the public sections with error %v", mode, importErr)
+		case mode == encryptEntry && (err == nil || importErr == nil):
+			t.Errorf("%s: read an entry encrypted as a whole with the wrong passphrase", mode)
+		}
+
+		t.Setenv(passphraseEnv, "correct horse")
+		conf, err = ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jrn, err := conf.ImportJournalContext(context.Background(), fsys, ImportOptions{Unseal: true})
+		if err != nil || len(jrn.Entries) != 4 {
+			t.Fatalf("%s: imported %d entries with error %v", mode, len(jrn.Entries), err)
+		}
+		if e := jrn.Entries["2019-01-02"]; len(e.Sections) != 1 || e.Sections[0].Body != "see notes-1.txt" {
+			t.Errorf("%s: imported %+v, expected the public Learn section", mode, e)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// garbage is an attachment that is not mentioned by its entry, or is mentioned but left out of exports.
+type garbage struct {
+	Path     string
+	Size     int64
+	Unlinked bool
+	// Reason is why the attachment is reported.
+	Reason string
+}
+
+// GCCommand reports the attachments their entries do not mention, and removes them if -remove is given.
+// It also reports the attachments mentioned by public sections that exports leave out, as the config asks.
+func GCCommand(fs *flag.FlagSet, _ *Config) runFunc {
+	remove := fs.Bool("remove", false, "remove the attachments their entries do not mention")
+	return func(conf *Config, args []string) error {
+		if *remove {
+			unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
+		found, err := conf.findGarbage()
+		if err != nil {
+			return err
+		}
+		var unlinked []string
+		var size int64
+		for _, g := range found {
+			fmt.Printf("%s (%s): %s\n", g.Path, humanSize(g.Size), g.Reason)
+			if g.Unlinked {
+				unlinked = append(unlinked, g.Path)
+				size += g.Size
+			}
+		}
+		if len(unlinked) == 0 {
+			fmt.Println("no unlinked attachments")
+			return nil
+		}
+		if !*remove {
+			fmt.Printf("%s, %s; remove them with devj gc -remove\n", plural(len(unlinked), "unlinked attachment"), humanSize(size))
+			return nil
+		}
+
+		var paths []string
+		for _, p := range unlinked {
+			if err := conf.FS.Remove(p); err != nil {
+				return err
+			}
+			paths = append(paths, filepath.FromSlash(p))
+		}
+		fmt.Printf("removed %s, %s\n", plural(len(unlinked), "unlinked attachment"), humanSize(size))
+		if !conf.Git.AutoCommit {
+			return nil
+		}
+		return GitCommit(".", paths, fmt.Sprintf("gc: remove %s", plural(len(unlinked), "unlinked attachment")))
+	}
+}
+
+// findGarbage lists the attachments of every entry that the entry does not mention,
+// and those that its public sections mention but the config leaves out of exports, in date order.
+func (c *Config) findGarbage() ([]garbage, error) {
+	dates, err := c.Layout.List(c.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []garbage
+	for _, date := range dates {
+		names, err := c.attachments(date)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			continue
+		}
+		e, _, err := c.readEntry(date)
+		if err != nil {
+			return nil, err
+		}
+		public := e.Public(c.PublicSections)
+		for _, name := range names {
+			p := path.Join(c.Layout.Dir(date), name)
+			info, err := fs.Stat(c.FS, p)
+			if err != nil {
+				return nil, err
+			}
+			g := garbage{Path: p, Size: info.Size()}
+			if len(e.Mentions(name)) == 0 {
+				g.Unlinked, g.Reason = true, "not mentioned by its entry"
+			} else if skip := c.Attachments.Skips(name, info.Size()); skip != "" && len(public.Mentions(name)) > 0 {
+				g.Reason = "not exported, " + skip
+			} else {
+				continue
+			}
+			found = append(found, g)
+		}
+	}
+	return found, nil
+}
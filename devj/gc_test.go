@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+func TestConfig_FindGarbage(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 3)
+	fsys.WriteFile("2019-01-01/screenshot.png", []byte("png"))
+	fsys.WriteFile("2019-01-02/2019-01-02.md", []byte("# Do\n\nsee notes-1.txt\n\n# Learn\n\nsee dump.log\n"))
+	fsys.WriteFile("2019-01-02/dump.log", []byte("log"))
+	fsys.WriteFile("2019-01-03/notes-2.txt", make([]byte, 5000))
+	conf := &Config{
+		PublicSections: map[string]struct{}{"learn": {}},
+		Attachments:    entry.FileFilter{MaxSize: 4096, Ignore: []string{"*.log"}},
+		FS:             fsys,
+	}
+
+	found, err := conf.findGarbage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []garbage{
+		{Path: "2019-01-01/screenshot.png", Size: 3, Unlinked: true, Reason: "not mentioned by its entry"},
+		{Path: "2019-01-02/dump.log", Size: 3, Reason: "not exported, ignored as *.log"},
+		{Path: "2019-01-03/notes-2.txt", Size: 5000, Reason: "not exported, larger than 4096 bytes"},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf(`Actual: "%+v" Expected: "%+v"`, found, expected)
+	}
+
+	// Exports leave out the same files.
+	jrn, err := conf.ImportJournal(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, files := range map[entry.EntryName]int{"2019-01-01": 1, "2019-01-02": 0, "2019-01-03": 0} {
+		if n := len(jrn.Entries[name].PublicFiles); n != files {
+			t.Errorf("%s exported %d files, expected %d", name, n, files)
+		}
+	}
+}
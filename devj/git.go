@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// GitConfig controls committing a journal kept in a git repository.
+type GitConfig struct {
+	// AutoCommit commits the entry devj new creates, or devj edit changes, once it is written.
+	// devj has no add command; devj attach, which adds a file to today's entry, stands in for it,
+	// committing the file along with the entry.
+	AutoCommit bool `json:"auto_commit"`
+	// Remote and Branch are what devj sync pulls from and pushes to.
+	// When empty, the upstream of the current branch is used.
+	Remote string `json:"remote"`
+	Branch string `json:"branch"`
+}
+
+// SyncCommand pulls the journal's new commits, putting ours on top of them, and pushes ours.
+// A remote given as an argument is used instead of the configured one.
+func SyncCommand(conf *Config, args []string) error {
+	remote := conf.Git.Remote
+	if len(args) == 1 {
+		remote = args[0]
+	}
+	unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return GitSync(".", remote, conf.Git.Branch)
+}
+
+// autoCommit commits the entry for date if the config asks for it, with a message describing
+// how it changed from before to after, as CommitMessage does. Any other files named are committed with it.
+func (c *Config) autoCommit(date entry.Date, before, after *entry.Entry, names ...string) error {
+	if !c.Git.AutoCommit {
+		return nil
+	}
+	paths := []string{filepath.FromSlash(c.Layout.Path(date))}
+	for _, name := range names {
+		paths = append(paths, filepath.FromSlash(name))
+	}
+	return GitCommit(".", paths, CommitMessage(date, before, after))
+}
+
+// CommitMessage describes a change to the entry for date, such as "2019-01-31: edit Do, Learn",
+// naming the sections that were added, changed or removed.
+// before is nil for a new entry, and after is nil if the entry cannot be read.
+func CommitMessage(date entry.Date, before, after *entry.Entry) string {
+	if before == nil {
+		return fmt.Sprintf("%s: new entry", date)
+	}
+	if after == nil {
+		return fmt.Sprintf("%s: edit", date)
+	}
+
+	changed := before.ChangedSections(*after)
+	if len(changed) == 0 {
+		return fmt.Sprintf("%s: edit", date)
+	}
+	return fmt.Sprintf("%s: edit %s", date, strings.Join(changed, ", "))
+}
+
+// GitCommit stages the files at paths in the git repository holding dir, relative to dir,
+// and commits them alone with message. Nothing is committed if they have not changed.
+func GitCommit(dir string, paths []string, message string) error {
+	if err := git(dir, append([]string{"add", "--"}, paths...)...); err != nil {
+		return err
+	}
+	// diff exits with 1 when there are changes.
+	cmd := exec.Command("git", append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...)
+	cmd.Dir = dir
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err == nil {
+		return nil
+	} else if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return fmt.Errorf("git diff: %v", err)
+	}
+	return git(dir, append([]string{"commit", "-q", "-m", message, "--"}, paths...)...)
+}
+
+// GitSync pulls from remote and branch, rebasing local commits onto what was pulled, and then pushes them.
+// Empty remote and branch use the upstream of the current branch.
+func GitSync(dir, remote, branch string) error {
+	var where []string
+	if remote != "" {
+		where = append(where, remote)
+		if branch != "" {
+			where = append(where, branch)
+		}
+	}
+	if err := git(dir, append([]string{"pull", "-q", "--rebase"}, where...)...); err != nil {
+		return err
+	}
+	return git(dir, append([]string{"push", "-q"}, where...)...)
+}
+
+// GitRemotes lists the remotes of the git repository holding dir.
+func GitRemotes(dir string) ([]string, error) {
+	cmd := exec.Command("git", "remote")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git remote: %v", err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// git runs git in dir, returning its output in the error if it fails.
+func git(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v\n%s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+func TestCommitMessage(t *testing.T) {
+	date, _ := entry.ParseDate("2019-01-31")
+	before := &entry.Entry{Sections: []entry.Section{{Title: "Do", Body: "a"}, {Title: "Learn", Body: "b"}, {Title: "Blockers"}}}
+
+	tests := map[string]struct {
+		Before, After *entry.Entry
+		Expected      string
+	}{
+		"new":        {After: before, Expected: "2019-01-31: new entry"},
+		"unreadable": {Before: before, Expected: "2019-01-31: edit"},
+		"unchanged":  {Before: before, After: before, Expected: "2019-01-31: edit"},
+		"changed": {Before: before, After: &entry.Entry{Sections: []entry.Section{
+			{Title: "Do", Body: "a, b"}, {Title: "Learn", Body: "b"}, {Title: "Ideas"}}},
+			Expected: "2019-01-31: edit Do, Ideas, Blockers"},
+	}
+
+	for id, test := range tests {
+		if msg := CommitMessage(date, test.Before, test.After); msg != test.Expected {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, msg, test.Expected, id)
+		}
+	}
+}
+
+// gitRepo makes a temporary git repository, a clone of remote if it is not empty.
+func gitRepo(t *testing.T, remote string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "devj-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if remote == "" {
+		mustGit(t, dir, "init", "-q", "-b", "main")
+	} else {
+		mustGit(t, dir, "clone", "-q", remote, ".")
+	}
+	mustGit(t, dir, "config", "user.name", "Test")
+	mustGit(t, dir, "config", "user.email", "test@example.com")
+	return dir
+}
+
+func mustGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGitCommit(t *testing.T) {
+	dir := gitRepo(t, "")
+	for _, name := range []string{"entry.md", "other.md"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("# Do\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := GitCommit(dir, []string{"entry.md"}, "2019-01-31: new entry"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := mustGit(t, dir, "log", "-1", "--format=%s"); msg != "2019-01-31: new entry" {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, msg, "2019-01-31: new entry")
+	}
+	// Only the given files are committed.
+	if status := mustGit(t, dir, "status", "--porcelain"); status != "?? other.md" {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, status, "?? other.md")
+	}
+	// Committing an unchanged file does nothing.
+	if err := GitCommit(dir, []string{"entry.md"}, "2019-01-31: edit"); err != nil {
+		t.Fatal(err)
+	}
+	if count := mustGit(t, dir, "rev-list", "--count", "HEAD"); count != "1" {
+		t.Errorf("got %s commits, expected 1", count)
+	}
+}
+
+func TestGitSync(t *testing.T) {
+	remote := gitRepo(t, "")
+	mustGit(t, remote, "commit", "-q", "--allow-empty", "-m", "start")
+	mustGit(t, remote, "config", "receive.denyCurrentBranch", "updateInstead")
+	mine, theirs := gitRepo(t, remote), gitRepo(t, remote)
+
+	for dir, name := range map[string]string{mine: "mine.md", theirs: "theirs.md"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("# Do\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := GitCommit(dir, []string{name}, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := GitSync(theirs, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Both commits end up in the remote, with mine rebased on theirs.
+	if err := GitSync(mine, "origin", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if log := mustGit(t, remote, "log", "--format=%s"); log != "mine.md\ntheirs.md\nstart" {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, log, "mine.md\ntheirs.md\nstart")
+	}
+}
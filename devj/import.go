@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifo/dev.journal/cache"
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// ImportOptions controls how ImportJournalContext reads a journal.
+type ImportOptions struct {
+	// Workers is the number of entries read and parsed at once.
+	// Zero uses one worker per CPU, and 1 reads the entries one at a time.
+	Workers int
+	// AllErrors makes the import report every entry that failed, instead of only the earliest one.
+	AllErrors bool
+	// NoCache parses every entry file instead of using and updating the journal's cache.
+	NoCache bool
+	// Unseal reads the public sections of entries encrypted as a whole, asking for the journal's key,
+	// instead of leaving them out.
+	Unseal bool
+	// From and To limit the import to the entries written from through to, inclusive.
+	// A zero date leaves that end of the range open.
+	From, To entry.Date
+}
+
+// ImportError is the error for a single entry that failed to import.
+type ImportError struct {
+	Date entry.Date
+	Err  error
+}
+
+func (e ImportError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Date, e.Err)
+}
+
+// ImportErrors holds every entry that failed to import, sorted by date.
+type ImportErrors []ImportError
+
+func (e ImportErrors) Error() string {
+	var out []string
+	for _, err := range e {
+		out = append(out, err.Error())
+	}
+	return strings.Join(out, "\n")
+}
+
+func (c *Config) ImportJournal(fsys filesystem.FS) (*entry.Journal, error) {
+	return c.ImportJournalContext(context.Background(), fsys, ImportOptions{})
+}
+
+// ImportJournalContext reads every entry in fsys using a pool of workers.
+// Unchanged entries are read from the journal's cache unless opts.NoCache is set.
+// Entries encrypted as a whole have no public sections to read without the key, so they are left out
+// unless opts.Unseal is set.
+// Errors are reported in date order no matter which worker finished first: the earliest failing entry
+// as an ImportError, or every failing entry as ImportErrors if opts.AllErrors is set.
+func (c *Config) ImportJournalContext(ctx context.Context, fsys filesystem.FS, opts ImportOptions) (*entry.Journal, error) {
+	all, err := c.Layout.List(fsys)
+	if err != nil {
+		return nil, err
+	}
+	var dates []entry.Date
+	for _, date := range all {
+		if (opts.From.IsZero() || !date.Before(opts.From)) && (opts.To.IsZero() || !date.After(opts.To)) {
+			dates = append(dates, date)
+		}
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(dates) {
+		workers = len(dates)
+	}
+
+	// The key is asked for once, before the workers need it.
+	if opts.Unseal && c.Encryption.Mode == encryptEntry {
+		if _, err := c.journalKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	var ch *cache.Cache
+	if !opts.NoCache {
+		ch = c.openCache(fsys)
+	}
+
+	entries := make([]entry.Entry, len(dates))
+	times := make([]time.Time, len(dates))
+	errs := make([]error, len(dates))
+	if workers <= 1 {
+		for i, date := range dates {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			entries[i], times[i], errs[i] = c.importEntry(fsys, date, ch, opts.Unseal)
+			if failed(errs[i]) && !opts.AllErrors {
+				break
+			}
+		}
+	} else {
+		c.importConcurrently(ctx, fsys, dates, ch, workers, opts, entries, times, errs)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var importErrs ImportErrors
+	for i, err := range errs {
+		if !failed(err) {
+			continue
+		}
+		if !opts.AllErrors {
+			return nil, ImportError{Date: dates[i], Err: err}
+		}
+		importErrs = append(importErrs, ImportError{Date: dates[i], Err: err})
+	}
+	if len(importErrs) > 0 {
+		return nil, importErrs
+	}
+
+	if ch != nil {
+		// Entries left out of the range were not looked up, but are still there.
+		if len(dates) == len(all) {
+			ch.Prune()
+		}
+		if err := ch.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := entry.NewJournal()
+	for i := range dates {
+		if errs[i] == nil {
+			out.AddAt(entries[i], times[i])
+		}
+	}
+	return out, nil
+}
+
+// failed reports whether err is an entry failing to import, rather than one left out because it is encrypted.
+func failed(err error) bool {
+	return err != nil && !errors.Is(err, entry.ErrSealed)
+}
+
+// importConcurrently fills entries, times and errs, which are indexed like dates.
+// Unless opts.AllErrors is set, entries after the earliest failure found so far are skipped,
+// since they can no longer change the error that is reported.
+func (c *Config) importConcurrently(ctx context.Context, fsys filesystem.FS, dates []entry.Date, ch *cache.Cache,
+	workers int, opts ImportOptions, entries []entry.Entry, times []time.Time, errs []error) {
+
+	var mu sync.Mutex
+	firstErr := len(dates)
+	skip := func(i int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return !opts.AllErrors && i > firstErr
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil || skip(i) {
+					continue
+				}
+				entries[i], times[i], errs[i] = c.importEntry(fsys, dates[i], ch, opts.Unseal)
+				if failed(errs[i]) {
+					mu.Lock()
+					if i < firstErr {
+						firstErr = i
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range dates {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// importEntry reads the public parts of the entry for date, and the files they mention,
+// along with the time the entry was last changed. It returns entry.ErrSealed for an entry encrypted as a whole,
+// unless unseal is set, in which case it is decrypted.
+// The entry file and the list of files in its folder come from ch, if it is not nil.
+func (c *Config) importEntry(fsys filesystem.FS, date entry.Date, ch *cache.Cache, unseal bool) (entry.Entry, time.Time, error) {
+	entryPath, entryDir := c.Layout.Path(date), c.Layout.Dir(date)
+
+	var e entry.Entry
+	var files []string
+	var modTime time.Time
+	if ch != nil {
+		f, err := ch.Lookup(entryPath, entryDir)
+		if err != nil {
+			return entry.Entry{}, modTime, err
+		}
+		if f.Sealed && !unseal {
+			return entry.Entry{}, f.ModTime, entry.ErrSealed
+		}
+		if f.Sealed {
+			raw, err := fsys.ReadFile(entryPath)
+			if err != nil {
+				return entry.Entry{}, f.ModTime, err
+			}
+			if e, err = c.importSealed(string(raw)); err != nil {
+				return entry.Entry{}, f.ModTime, err
+			}
+		} else {
+			e = f.Entry.Public(c.PublicSections)
+		}
+		files = f.Attachments
+		modTime = f.ModTime
+	} else {
+		info, err := fsys.Stat(entryPath)
+		if err != nil {
+			return entry.Entry{}, modTime, err
+		}
+		modTime = info.ModTime()
+		rawEntry, err := fsys.ReadFile(entryPath)
+		if err != nil {
+			return entry.Entry{}, modTime, err
+		}
+		e, err = entry.ImportPublic(string(rawEntry), c.PublicSections)
+		if errors.Is(err, entry.ErrSealed) && unseal {
+			e, err = c.importSealed(string(rawEntry))
+		}
+		if err != nil {
+			return entry.Entry{}, modTime, err
+		}
+		if files, err = filesystem.ListFiles(fsys, entryDir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return entry.Entry{}, modTime, err
+		}
+	}
+
+	e.Name = date.Name()
+	e.FileNames = map[string]struct{}{}
+	for _, name := range files {
+		if path.Join(entryDir, name) != entryPath {
+			e.FileNames[name] = struct{}{}
+		}
+	}
+
+	if err := e.ImportFiles(c.PublicSections, fsys, entryDir, c.Attachments); err != nil {
+		return entry.Entry{}, modTime, err
+	}
+	return e, modTime, nil
+}
+
+// importSealed reads the public sections of raw, an entry encrypted as a whole.
+func (c *Config) importSealed(raw string) (entry.Entry, error) {
+	plain, err := c.decrypt(raw)
+	if err != nil {
+		return entry.Entry{}, err
+	}
+	return entry.ImportPublic(plain, c.PublicSections)
+}
+
+// LoadJournal reads every entry in fsys in full, private sections included, decrypting those that are encrypted.
+// It is meant for commands that only show the journal to its owner, such as search.
+// Entries come from the journal's cache, which holds them whole as long as the journal is not encrypted;
+// encrypted entries are read and decrypted every time, so their private sections are never cached.
+func (c *Config) LoadJournal(fsys filesystem.FS) (*entry.Journal, error) {
+	dates, err := c.Layout.List(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := c.openCache(fsys)
+	out := entry.NewJournal()
+	for _, date := range dates {
+		entryPath, entryDir := c.Layout.Path(date), c.Layout.Dir(date)
+		f, err := ch.Lookup(entryPath, entryDir)
+		if err != nil {
+			return nil, ImportError{Date: date, Err: err}
+		}
+		var e entry.Entry
+		if f.Full != nil {
+			e = *f.Full
+			e.Sections = append([]entry.Section(nil), f.Full.Sections...)
+		} else {
+			raw, err := fsys.ReadFile(entryPath)
+			if err != nil {
+				return nil, ImportError{Date: date, Err: err}
+			}
+			plain, err := c.decrypt(string(raw))
+			if err != nil {
+				return nil, ImportError{Date: date, Err: err}
+			}
+			if e, err = entry.Import(plain); err != nil {
+				return nil, ImportError{Date: date, Err: err}
+			}
+		}
+		e.Name = date.Name()
+		e.FileNames = map[string]struct{}{}
+		for _, name := range f.Attachments {
+			e.FileNames[name] = struct{}{}
+		}
+		out.Entries[e.Name] = e
+	}
+	ch.Prune()
+	if err := ch.Save(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
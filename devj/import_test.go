@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+var importConf = &Config{PublicSections: map[string]struct{}{"learn": {}}}
+
+// makeJournal writes days entries starting on 2019-01-01 into a temporary directory, like writeJournal.
+func makeJournal(tb testing.TB, days int, broken ...string) string {
+	dir, err := ioutil.TempDir("", "devj")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	writeJournal(tb, filesystem.OS(dir), days, broken...)
+	return dir
+}
+
+// writeJournal writes days entries starting on 2019-01-01 into fsys.
+// Entries for the dates in broken do not start with a title, so they fail to import.
+func writeJournal(tb testing.TB, fsys filesystem.FS, days int, broken ...string) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < days; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		contents := fmt.Sprintf("# Do\n\nthings %d\n\n# Learn\n\nsee notes-%d.txt\n", i, i)
+		for _, b := range broken {
+			if b == date {
+				contents = "not a title"
+			}
+		}
+		if err := fsys.MkdirAll(date); err != nil {
+			tb.Fatal(err)
+		}
+		if err := fsys.WriteFile(date+"/"+date+".md", []byte(contents)); err != nil {
+			tb.Fatal(err)
+		}
+		if err := fsys.WriteFile(fmt.Sprintf("%s/notes-%d.txt", date, i), make([]byte, 4096)); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestConfig_ImportJournalContext(t *testing.T) {
+	dir := fstest.NewMemFS()
+	writeJournal(t, dir, 40)
+
+	sequential, err := importConf.ImportJournalContext(context.Background(), dir,
+		ImportOptions{Workers: 1, NoCache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sequential.Entries) != 40 {
+		t.Fatalf("got %d entries, expected 40", len(sequential.Entries))
+	}
+
+	// The first cached import fills the cache, and the second reads from it.
+	for _, opts := range []ImportOptions{{Workers: 8}, {Workers: 8}, {Workers: 1}} {
+		jrn, err := importConf.ImportJournalContext(context.Background(), dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sequential, jrn) {
+			t.Errorf("import with %+v differs from sequential import", opts)
+		}
+	}
+}
+
+func TestConfig_ImportJournalContext_Range(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 5)
+	if _, err := importConf.ImportJournal(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	from, _ := entry.ParseDate("2019-01-02")
+	to, _ := entry.ParseDate("2019-01-03")
+	jrn, err := importConf.ImportJournalContext(context.Background(), fsys, ImportOptions{From: from, To: to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := jrn.Names(); !reflect.DeepEqual(names, []entry.EntryName{"2019-01-02", "2019-01-03"}) {
+		t.Errorf(`Actual: "%v" Expected: "%v"`, names, []string{"2019-01-02", "2019-01-03"})
+	}
+	// The entries out of range stay cached.
+	var paths []string
+	for _, date := range []string{"2019-01-01", "2019-01-05"} {
+		paths = append(paths, date+"/"+date+".md")
+	}
+	if missing := importConf.openCache(fsys).Missing(paths); len(missing) != 0 {
+		t.Errorf("entries out of range were dropped from the cache: %v", missing)
+	}
+}
+
+// countingFS counts the entry files read from it.
+type countingFS struct {
+	*fstest.MemFS
+	reads int
+}
+
+func (c *countingFS) ReadFile(name string) ([]byte, error) {
+	if strings.HasSuffix(name, ".md") {
+		c.reads++
+	}
+	return c.MemFS.ReadFile(name)
+}
+
+func TestConfig_LoadJournal(t *testing.T) {
+	fsys := &countingFS{MemFS: fstest.NewMemFS()}
+	writeJournal(t, fsys.MemFS, 3)
+	conf := &Config{PublicSections: importConf.PublicSections, FS: fsys}
+
+	loaded, err := conf.LoadJournal(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := loaded.Entries["2019-01-02"]; len(e.Sections) != 2 || e.Sections[0].Body != "things 1" {
+		t.Errorf("loaded %+v, expected every section", e)
+	}
+
+	// Loading again reads the entries from the cache, not from their files.
+	fsys.reads = 0
+	cached, err := conf.LoadJournal(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsys.reads != 0 {
+		t.Errorf("read %d entry files, expected them all to come from the cache", fsys.reads)
+	}
+	if !reflect.DeepEqual(cached, loaded) {
+		t.Errorf(`Actual: "%+v" Expected: "%+v"`, cached.Entries, loaded.Entries)
+	}
+}
+
+func TestConfig_ImportJournalContext_Errors(t *testing.T) {
+	dir := fstest.NewMemFS()
+	writeJournal(t, dir, 40, "2019-01-30", "2019-01-05", "2019-01-20")
+
+	tests := map[string]struct {
+		Opts ImportOptions
+		Err  string
+	}{
+		"sequential first": {
+			Opts: ImportOptions{Workers: 1},
+			Err:  "2019-01-05: entries must start with a title"},
+		"concurrent first": {
+			Opts: ImportOptions{Workers: 8},
+			Err:  "2019-01-05: entries must start with a title"},
+		"concurrent all": {
+			Opts: ImportOptions{Workers: 8, AllErrors: true},
+			Err: "2019-01-05: entries must start with a title\n" +
+				"2019-01-20: entries must start with a title\n" +
+				"2019-01-30: entries must start with a title"},
+	}
+
+	for id, test := range tests {
+		_, err := importConf.ImportJournalContext(context.Background(), dir, test.Opts)
+		if err == nil || err.Error() != test.Err {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, err, test.Err, id)
+		}
+	}
+}
+
+func TestConfig_ImportJournalContext_Cancel(t *testing.T) {
+	dir := fstest.NewMemFS()
+	writeJournal(t, dir, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, workers := range []int{1, 4} {
+		_, err := importConf.ImportJournalContext(ctx, dir, ImportOptions{Workers: workers})
+		if err != context.Canceled {
+			t.Errorf("got %v with %d workers, expected %v", err, workers, context.Canceled)
+		}
+	}
+}
+
+func benchmarkImport(b *testing.B, opts ImportOptions) {
+	root := makeJournal(b, 500)
+	defer os.RemoveAll(root)
+	dir := filesystem.OS(root)
+	if !opts.NoCache {
+		// Fill the cache first.
+		if _, err := importConf.ImportJournalContext(context.Background(), dir, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := importConf.ImportJournalContext(context.Background(), dir, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkImportJournal_Sequential(b *testing.B) {
+	benchmarkImport(b, ImportOptions{Workers: 1, NoCache: true})
+}
+func BenchmarkImportJournal_Concurrent(b *testing.B) {
+	benchmarkImport(b, ImportOptions{NoCache: true})
+}
+func BenchmarkImportJournal_Cached(b *testing.B) { benchmarkImport(b, ImportOptions{}) }
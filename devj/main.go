@@ -5,76 +5,66 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/ifo/dev.journal/entry"
 	"github.com/ifo/dev.journal/filesystem"
 )
 
+// workDir is the folder devj was run from, which paths given on the command line are relative to.
+var workDir string
+
 func main() {
-	if len(os.Args) == 1 {
-		fmt.Println("no command given")
-		return
-	}
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	conf, err := ReadConfig()
-	if err != nil {
-		log.Fatal(err)
+// NewCommand makes today's entry.
+func NewCommand(conf *Config, args []string) error {
+	if err := MakeNewEntry(conf); err != nil {
+		return err
 	}
+	fmt.Println("new entry created")
+	return nil
+}
 
-	switch strings.ToLower(os.Args[1]) {
-	case "new":
-		err := MakeNewEntry()
-		if err != nil {
-			log.Fatal(err)
-		}
-		fmt.Println("new entry created")
+// EditCommand opens the latest entry in the editor.
+func EditCommand(conf *Config, args []string) error {
+	return EditEntry(conf)
+}
 
-	case "edit":
-		if err := EditEntry(conf); err != nil {
-			log.Fatal(err)
+// ExportCommand sends the public parts of the journal to a devj server.
+func ExportCommand(fs *flag.FlagSet, _ *Config) runFunc {
+	url := fs.String("url", "", "url to send the journal to")
+	user := fs.String("user", "", "username")
+	pass := fs.String("pass", "", "password")
+	return func(conf *Config, args []string) error {
+		if *url == "" || *user == "" || *pass == "" {
+			return usagef("need all of -url, -user and -pass")
 		}
-
-	case "export":
-		if err := ExportJournal(conf); err != nil {
-			log.Fatal(err)
+		if !strings.HasPrefix(*url, "https://") {
+			return usagef(`the url must use https (so must start with "https://")`)
+		}
+		if err := ExportJournal(conf, *url, *user, *pass); err != nil {
+			return err
 		}
 		fmt.Println("journal export complete")
-
-	case "viewconfig":
-		fmt.Println(conf)
-
-	default:
-		fmt.Println("unknown command")
+		return nil
 	}
 }
 
-func ExportJournal(conf *Config) error {
-	jrn, err := conf.ImportJournal(".")
+// ExportJournal posts the public parts of the journal to url, as user.
+func ExportJournal(conf *Config, url, user, pass string) error {
+	jrn, err := conf.ImportJournal(conf.FS)
 	if err != nil {
 		return err
 	}
 
-	var url, user, pass string
-	flag.StringVar(&url, "url", "", "url to send the journal to")
-	flag.StringVar(&user, "user", "", "username")
-	flag.StringVar(&pass, "pass", "", "password")
-	flag.Parse()
-
-	if user == "" || pass == "" {
-		log.Fatal("need both url, user and password")
-	}
-	if !strings.HasPrefix(url, "https://") {
-		log.Fatal(`the url must use https (so must start with "https://")`)
-	}
-
+	jrn.Timezone = conf.Clock.Zone()
 	body, err := json.Marshal(jrn)
 	if err != nil {
 		return err
@@ -96,36 +86,89 @@ func ExportJournal(conf *Config) error {
 	return nil
 }
 
-func MakeNewEntry() error {
-	folder := filesystem.DateString(time.Now())
-	file := fmt.Sprintf("%s.md", folder)
+func MakeNewEntry(conf *Config) error {
+	unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	today := conf.Today()
+	name := conf.Layout.Path(today)
 
 	contents := entry.Default.Export()
 
 	// Overwrite contents with the last journal, to give a better starting journal.
-	if fname := filesystem.Latest(); fname != "" {
-		bts, err := ioutil.ReadFile(fname)
+	if latest, ok := conf.Layout.LatestBefore(conf.FS, today); ok {
+		bts, err := conf.FS.ReadFile(conf.Layout.Path(latest))
 		if err != nil {
 			return err
 		}
 		contents = string(bts)
 	}
 
-	if err := filesystem.EnsureFolderExists(folder); err != nil {
+	// In an encrypted journal the new entry is encrypted from the start, since it may never be edited.
+	if conf.Encryption.Mode != "" {
+		key, err := conf.journalKey()
+		if err != nil {
+			return err
+		}
+		plain, err := conf.decrypt(contents)
+		if err != nil {
+			return err
+		}
+		if contents, err = conf.sealEntry(key, plain, contents); err != nil {
+			return err
+		}
+	}
+
+	if err := filesystem.EnsureFolderExists(conf.FS, path.Dir(name)); err != nil {
 		return err
 	}
 
-	return filesystem.SafeWriteFile(filepath.Join(folder, file), []byte(contents))
+	if err := filesystem.SafeWriteFile(conf.FS, name, []byte(contents)); err != nil {
+		return err
+	}
+	return conf.autoCommit(today, nil, nil)
 }
 
 func EditEntry(conf *Config) error {
-	pe := filesystem.Latest()
+	pe := conf.Layout.Latest(conf.FS, conf.Today())
 	if pe == "" {
 		return fmt.Errorf("no entry to edit")
 	}
-	cmd := exec.Command(conf.EditorCommand, pe)
+	date, err := conf.Layout.Date(pe)
+	if err != nil {
+		return err
+	}
+	return openEntry(conf, date)
+}
+
+// openEntry opens the entry for date in the editor, and commits it once the editor exits
+// if the config asks for it.
+func openEntry(conf *Config, date entry.Date) error {
+	if conf.Encryption.Mode != "" {
+		return openSealedEntry(conf, date)
+	}
+
+	// An entry that cannot be read counts as empty, so every section in it is new.
+	before := &entry.Entry{}
+	if raw, err := conf.FS.ReadFile(conf.Layout.Path(date)); err == nil {
+		before = importOrEmpty(string(raw))
+	}
+
+	cmd := exec.Command(conf.EditorCommand, filepath.FromSlash(conf.Layout.Path(date)))
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", conf.EditorCommand, err)
+	}
+	var after *entry.Entry
+	if raw, err := conf.FS.ReadFile(conf.Layout.Path(date)); err == nil {
+		if e, err := entry.Import(string(raw)); err == nil {
+			after = &e
+		}
+	}
+	return conf.autoCommit(date, before, after)
 }
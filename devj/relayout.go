@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// move is a file or folder to be moved, relative to the journal root.
+type move struct {
+	from, to string
+	// attachment is set for everything but entry files.
+	attachment bool
+}
+
+// RelayoutCommand moves every entry, and its attachments, from the configured layout to a new one,
+// and then makes the new layout the configured one.
+// It only shows what would move unless -apply is given.
+func RelayoutCommand(fs *flag.FlagSet, _ *Config) runFunc {
+	apply := fs.Bool("apply", false, "move the files instead of only showing what would move")
+	return func(conf *Config, args []string) error {
+		to, err := filesystem.ParseLayout(args[0])
+		if err != nil {
+			return usagef("%v", err)
+		}
+
+		// The moves are planned under the lock too, so nothing written in between is left behind.
+		if *apply {
+			unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
+		moves, err := planRelayout(conf.FS, conf.Layout, to)
+		if err != nil {
+			return err
+		}
+		entries := 0
+		for _, m := range moves {
+			if !m.attachment {
+				entries++
+			}
+			fmt.Printf("%s -> %s\n", m.from, m.to)
+		}
+		if !*apply {
+			fmt.Printf("%s and %s would move to %s; run again with -apply to move them\n",
+				plural(entries, "entry file"), plural(len(moves)-entries, "attachment"), to)
+			return nil
+		}
+
+		if err := applyMoves(conf.FS, moves); err != nil {
+			return err
+		}
+		if err := WriteLayout(conf.FS, configFile, to); err != nil {
+			return err
+		}
+		conf.Layout = to
+		// Every cached path has changed.
+		ch := conf.openCache(conf.FS)
+		ch.Reset()
+		if err := ch.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("%s and %s moved to %s\n", plural(entries, "entry file"), plural(len(moves)-entries, "attachment"), to)
+		return nil
+	}
+}
+
+// planRelayout lists the moves taking every entry in fsys from one layout to the other.
+// It fails if anything would be moved onto a file or folder that is already there.
+func planRelayout(fsys filesystem.FS, from, to filesystem.Layout) ([]move, error) {
+	dates, err := from.List(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	var moves []move
+	taken := map[string]struct{}{}
+	add := func(m move) error {
+		if m.from == m.to {
+			return nil
+		}
+		if _, ok := taken[m.to]; ok {
+			return fmt.Errorf("cannot move %s to %s, something else is moving there", m.from, m.to)
+		}
+		if _, err := fsys.Stat(m.to); err == nil {
+			return fmt.Errorf("cannot move %s to %s, it already exists", m.from, m.to)
+		}
+		taken[m.to] = struct{}{}
+		moves = append(moves, m)
+		return nil
+	}
+
+	for _, date := range dates {
+		if err := add(move{from: from.Path(date), to: to.Path(date)}); err != nil {
+			return nil, err
+		}
+		fromDir, toDir := from.Dir(date), to.Dir(date)
+		if fromDir == toDir {
+			continue
+		}
+		names, err := filesystem.ListFiles(fsys, fromDir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if path.Join(fromDir, name) == from.Path(date) {
+				continue
+			}
+			m := move{from: path.Join(fromDir, name), to: path.Join(toDir, name), attachment: true}
+			if err := add(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return moves, nil
+}
+
+// applyMoves moves files and folders within fsys, and then removes the folders they leave empty.
+// Nothing is moved onto a file or folder that is already there. If a move fails, the moves
+// already made are undone, last first, so the journal is left in the layout it was in.
+func applyMoves(fsys filesystem.FS, moves []move) error {
+	for i, m := range moves {
+		err := filesystem.EnsureFolderExists(fsys, path.Dir(m.to))
+		if err == nil {
+			if _, statErr := fsys.Stat(m.to); statErr == nil {
+				err = fmt.Errorf("cannot move %s to %s, it already exists", m.from, m.to)
+			} else if !errors.Is(statErr, fs.ErrNotExist) {
+				err = statErr
+			}
+		}
+		if err == nil {
+			err = fsys.Rename(m.from, m.to)
+		}
+		if err != nil {
+			removeEmptyFolders(fsys, path.Dir(m.to))
+			return undoMoves(fsys, moves[:i], err)
+		}
+	}
+	for _, m := range moves {
+		removeEmptyFolders(fsys, path.Dir(m.from))
+	}
+	return nil
+}
+
+// undoMoves moves back the moves that were made before a move failed with err, last first,
+// and removes the folders made for them. It returns err, along with the moves it could not undo.
+func undoMoves(fsys filesystem.FS, done []move, err error) error {
+	var stuck []string
+	for i := len(done) - 1; i >= 0; i-- {
+		m := done[i]
+		if undoErr := fsys.Rename(m.to, m.from); undoErr != nil {
+			stuck = append(stuck, fmt.Sprintf("%s is still at %s: %v", m.from, m.to, undoErr))
+			continue
+		}
+		removeEmptyFolders(fsys, path.Dir(m.to))
+	}
+	if len(stuck) > 0 {
+		return fmt.Errorf("%v; undoing the moves made before it failed:\n%s", err, strings.Join(stuck, "\n"))
+	}
+	return fmt.Errorf("%v; the moves made before it were undone", err)
+}
+
+// removeEmptyFolders removes dir and the folders above it, up to the first that is not empty.
+func removeEmptyFolders(fsys filesystem.FS, dir string) {
+	// Removing a folder fails unless it is empty, which is what is wanted here.
+	for ; dir != "."; dir = path.Dir(dir) {
+		if fsys.Remove(dir) != nil {
+			break
+		}
+	}
+}
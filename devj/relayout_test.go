@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ifo/dev.journal/filesystem"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+func TestRelayout(t *testing.T) {
+	root := makeJournal(t, 3)
+	defer os.RemoveAll(root)
+	fsys := filesystem.OS(root)
+	if err := os.Mkdir(filepath.Join(root, ".devj-backup"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested, _ := filesystem.ParseLayout("{yyyy}/{mm}/{dd}.md")
+	var flat filesystem.Layout
+
+	moves, err := planRelayout(fsys, flat, nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 6 {
+		t.Fatalf("got %d moves, expected 3 entries and 3 attachments: %v", len(moves), moves)
+	}
+	if err := applyMoves(fsys, moves); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"2019/01/01.md", "2019/01/01/notes-0.txt", "2019/01/03.md", "2019/01/03/notes-2.txt"} {
+		if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+			t.Errorf("%s was not moved: %v", path, err)
+		}
+	}
+	if dirs, _ := filesystem.ListDirs(fsys, "."); !reflect.DeepEqual(dirs, []string{".devj-backup", "2019"}) {
+		t.Errorf("old folders were left behind: %v", dirs)
+	}
+	conf := &Config{PublicSections: importConf.PublicSections, Layout: nested}
+	jrn, err := conf.ImportJournal(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := jrn.Entries["2019-01-02"]; len(e.PublicFiles["notes-1.txt"]) != 4096 {
+		t.Errorf("attachment was lost: %+v", e)
+	}
+
+	// Moving back is the same as never having moved.
+	if moves, err = planRelayout(fsys, nested, flat); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyMoves(fsys, moves); err != nil {
+		t.Fatal(err)
+	}
+	original := makeJournal(t, 3)
+	defer os.RemoveAll(original)
+	for _, path := range []string{"2019-01-02/2019-01-02.md", "2019-01-02/notes-1.txt"} {
+		moved, err1 := ioutil.ReadFile(filepath.Join(root, path))
+		expected, err2 := ioutil.ReadFile(filepath.Join(original, path))
+		if err1 != nil || err2 != nil || string(moved) != string(expected) {
+			t.Errorf("%s changed: %v %v", path, err1, err2)
+		}
+	}
+
+	// Nothing moves onto a file that is already there.
+	if err := ioutil.WriteFile(filepath.Join(root, "2019-01-01.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dated, _ := filesystem.ParseLayout("{date}.md")
+	if _, err := planRelayout(fsys, flat, dated); err == nil {
+		t.Errorf("planned to overwrite 2019-01-01.md")
+	}
+}
+
+// failingRenameFS fails the rename numbered fail, counting from 1.
+type failingRenameFS struct {
+	*fstest.MemFS
+	renames, fail int
+}
+
+func (f *failingRenameFS) Rename(oldname, newname string) error {
+	f.renames++
+	if f.renames == f.fail {
+		return fmt.Errorf("rename %s: no space left on device", oldname)
+	}
+	return f.MemFS.Rename(oldname, newname)
+}
+
+func TestRelayout_FailedMove(t *testing.T) {
+	fsys := &failingRenameFS{MemFS: fstest.NewMemFS(), fail: 4}
+	writeJournal(t, fsys, 3)
+	before := fsys.Names()
+	nested, _ := filesystem.ParseLayout("{yyyy}/{mm}/{dd}.md")
+
+	moves, err := planRelayout(fsys, filesystem.Layout{}, nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyMoves(fsys, moves); err == nil || !strings.Contains(err.Error(), "were undone") {
+		t.Fatalf("Actual: %v Expected: the failed move, undone", err)
+	}
+	if after := fsys.Names(); !reflect.DeepEqual(after, before) {
+		t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, after, before, "undone moves")
+	}
+	if _, err := fsys.Stat("2019"); err == nil {
+		t.Errorf("the folders made for the undone moves were left behind")
+	}
+}
+
+func TestRelayout_ExistingDestination(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 2)
+	nested, _ := filesystem.ParseLayout("{yyyy}/{mm}/{dd}.md")
+	moves, err := planRelayout(fsys, filesystem.Layout{}, nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Written after the moves were planned.
+	fsys.MkdirAll("2019/01")
+	fsys.WriteFile("2019/01/02.md", []byte("written since"))
+	before := fsys.Names()
+
+	if err := applyMoves(fsys, moves); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Actual: %v Expected: a move onto 2019/01/02.md, refused", err)
+	}
+	if after := fsys.Names(); !reflect.DeepEqual(after, before) {
+		t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, after, before, "undone moves")
+	}
+}
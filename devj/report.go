@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+var projectRegex = regexp.MustCompile(`(^|\s)\+([A-Za-z][\w-]*)`)
+
+// reportsDir is the folder, relative to the journal root, that saved reports go in.
+// Layouts only have room for an entry a day, so a report is saved next to the entries rather than as one.
+const reportsDir = "reports"
+
+// ReportCommand prints a report of the public sections written during a week or a month,
+// and optionally saves it in the reports folder, since a report is not a day's entry.
+func ReportCommand(fs *flag.FlagSet, defaults *Config) runFunc {
+	week := fs.String("week", "", "the ISO week to report on, as YYYY-Www; defaults to this week")
+	month := fs.String("month", "", "the month to report on, as YYYY-MM")
+	sections := fs.String("sections", strings.Join(defaults.Report.Sections, ","), "comma separated sections to gather, in order")
+	groupBy := fs.String("group-by", defaults.Report.GroupBy, `group bullets by "tag", "project" or "none"`)
+	save := fs.Bool("save", false, "also save the report to "+reportsDir+"/<period>.md, next to the entries, which are one a day")
+	return func(conf *Config, args []string) error {
+		var period string
+		var from, to entry.Date
+		var err error
+		switch {
+		case *week != "" && *month != "":
+			return usagef("only one of -week and -month can be given")
+		case *month != "":
+			period = *month
+			from, to, err = entry.ParseMonth(*month)
+		default:
+			if *week == "" {
+				*week = conf.Today().Week()
+			}
+			period = *week
+			from, to, err = entry.ParseWeek(*week)
+		}
+		if err != nil {
+			return usagef("%v", err)
+		}
+		rc := ReportConfig{GroupBy: *groupBy}
+		for _, s := range strings.Split(*sections, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				rc.Sections = append(rc.Sections, s)
+			}
+		}
+		if rc.GroupBy != "tag" && rc.GroupBy != "project" && rc.GroupBy != "none" {
+			return usagef(`unknown grouping %q, use "tag", "project" or "none"`, rc.GroupBy)
+		}
+
+		// Only public sections are reported on, so the key is only needed for entries encrypted as a whole.
+		jrn, err := conf.ImportJournalContext(context.Background(), conf.FS, ImportOptions{Unseal: true, From: from, To: to})
+		if err != nil {
+			return err
+		}
+		title := fmt.Sprintf("%s %s to %s", period, from, to)
+		report := BuildReport(jrn, title, rc)
+		fmt.Print(report.Export())
+
+		if *save {
+			file, err := saveReport(conf.FS, period, report)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\nreport saved to %s\n", file)
+		}
+		return nil
+	}
+}
+
+// saveReport writes report for period to the reports folder of fsys, failing if one was saved before,
+// and returns the path it was written to.
+func saveReport(fsys filesystem.FS, period string, report entry.Entry) (string, error) {
+	unlock, err := filesystem.Lock(fsys, ".", filesystem.LockWait)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if err := filesystem.EnsureFolderExists(fsys, reportsDir); err != nil {
+		return "", err
+	}
+	file := path.Join(reportsDir, period+".md")
+	if err := filesystem.SafeWriteFile(fsys, file, []byte(report.Export())); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// reportItem is a bullet or task gathered for a report, from the first entry it appeared in.
+type reportItem struct {
+	text  string
+	group string
+	date  entry.EntryName
+	done  entry.EntryName
+}
+
+// BuildReport gathers the bullets of the sections in conf from every entry in jrn.
+// Bullets are grouped as conf says, and repeated bullets, such as those copied into the next day's entry,
+// are only listed once. Tasks from those sections are listed as completed, or as still open
+// if they were never checked off.
+func BuildReport(jrn *entry.Journal, title string, conf ReportConfig) entry.Entry {
+	bullets := map[string][]*reportItem{}
+	tasks := map[string]*reportItem{}
+	var taskOrder []*reportItem
+	seen := map[string]struct{}{}
+
+	entries := jrn.Ordered()
+	for _, e := range entries {
+		for _, s := range e.Sections {
+			title := reportSection(conf, s.Title)
+			if title == "" {
+				continue
+			}
+			for _, b := range s.Blocks() {
+				if b.List == nil {
+					continue
+				}
+				walkItems(b.List, func(item *entry.ListItem, top bool) {
+					text := strings.Join(strings.Fields(item.Text), " ")
+					key := strings.ToLower(text)
+					switch {
+					case item.Task:
+						t, ok := tasks[key]
+						if !ok {
+							t = &reportItem{text: text, date: e.Name}
+							tasks[key] = t
+							taskOrder = append(taskOrder, t)
+						}
+						if item.Done && t.done == "" {
+							t.done = e.Name
+						}
+					case top:
+						if _, ok := seen[title+"\n"+key]; ok {
+							return
+						}
+						seen[title+"\n"+key] = struct{}{}
+						bullets[title] = append(bullets[title], &reportItem{
+							text: text, group: reportGroup(conf.GroupBy, text), date: e.Name})
+					}
+				})
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("%d entries", len(entries))
+	if len(entries) == 1 {
+		summary = "1 entry"
+	}
+	report := entry.Entry{Style: entry.Pound, Sections: []entry.Section{{Title: title, Body: summary}}}
+	for _, s := range conf.Sections {
+		report.Sections = append(report.Sections, entry.Section{Title: s, Body: groupedBullets(bullets[s])})
+	}
+
+	var completed, open []string
+	for _, t := range taskOrder {
+		if t.done != "" {
+			completed = append(completed, fmt.Sprintf("- [x] %s (%s)", t.text, t.done))
+		} else {
+			open = append(open, fmt.Sprintf("- [ ] %s (since %s)", t.text, t.date))
+		}
+	}
+	report.Sections = append(report.Sections,
+		entry.Section{Title: "Completed", Body: strings.Join(completed, "\n")},
+		entry.Section{Title: "Open", Body: strings.Join(open, "\n")})
+	return report
+}
+
+// reportSection returns the title of the report section that the section titled title goes in,
+// or "" if it is not reported on.
+func reportSection(conf ReportConfig, title string) string {
+	for _, s := range conf.Sections {
+		if strings.EqualFold(s, title) {
+			return s
+		}
+	}
+	return ""
+}
+
+func reportGroup(groupBy, text string) string {
+	switch groupBy {
+	case "tag":
+		if tags := entry.FindTags(text); len(tags) > 0 {
+			return "#" + tags[0]
+		}
+	case "project":
+		if m := projectRegex.FindStringSubmatch(text); m != nil {
+			return "+" + strings.ToLower(m[2])
+		}
+	}
+	return ""
+}
+
+// groupedBullets lists items under a heading for each group, with ungrouped items last.
+func groupedBullets(items []*reportItem) string {
+	groups := map[string][]string{}
+	var names []string
+	for _, it := range items {
+		if _, ok := groups[it.group]; !ok && it.group != "" {
+			names = append(names, it.group)
+		}
+		groups[it.group] = append(groups[it.group], fmt.Sprintf("- %s (%s)", it.text, it.date))
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return strings.Join(groups[""], "\n")
+	}
+
+	var out []string
+	for _, name := range names {
+		out = append(out, fmt.Sprintf("### %s\n\n%s", name, strings.Join(groups[name], "\n")))
+	}
+	if other, ok := groups[""]; ok {
+		out = append(out, fmt.Sprintf("### Other\n\n%s", strings.Join(other, "\n")))
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// walkItems calls fn on every item of l and of the lists nested in it.
+func walkItems(l *entry.List, fn func(item *entry.ListItem, top bool)) {
+	var walk func(l *entry.List, top bool)
+	walk = func(l *entry.List, top bool) {
+		for _, item := range l.Items {
+			fn(item, top)
+			if item.Children != nil {
+				walk(item.Children, false)
+			}
+		}
+	}
+	walk(l, true)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+func TestBuildReport(t *testing.T) {
+	jrn := entry.NewJournal()
+	jrn.Add(entry.Entry{Name: "2026-10-12", Sections: []entry.Section{
+		{Title: "Do", Body: "- fixed the pager #oncall\n- [ ] write docs\n- [ ] ship it +api\n- reviewed PRs +api"},
+		{Title: "Private", Body: "- secret"},
+	}})
+	jrn.Add(entry.Entry{Name: "2026-10-13", Sections: []entry.Section{
+		{Title: "do", Body: "- [x] ship it +api\n  - [ ] follow up\n- reviewed PRs +api\n- lunch"},
+		{Title: "Learn", Body: "Paragraphs are skipped.\n\n- go generics #go"},
+	}})
+	conf := ReportConfig{Sections: []string{"Do", "Learn"}}
+
+	tests := map[string]struct {
+		GroupBy string
+		Out     string
+	}{
+		"tag": {GroupBy: "tag", Out: "# 2026-W42\n\n2 entries\n\n" +
+			"# Do\n\n### #oncall\n\n- fixed the pager #oncall (2026-10-12)\n\n" +
+			"### Other\n\n- reviewed PRs +api (2026-10-12)\n- lunch (2026-10-13)\n\n" +
+			"# Learn\n\n### #go\n\n- go generics #go (2026-10-13)\n\n" +
+			"# Completed\n\n- [x] ship it +api (2026-10-13)\n\n" +
+			"# Open\n\n- [ ] write docs (since 2026-10-12)\n- [ ] follow up (since 2026-10-13)\n"},
+		"project": {GroupBy: "project", Out: "# 2026-W42\n\n2 entries\n\n" +
+			"# Do\n\n### +api\n\n- reviewed PRs +api (2026-10-12)\n\n" +
+			"### Other\n\n- fixed the pager #oncall (2026-10-12)\n- lunch (2026-10-13)\n\n" +
+			"# Learn\n\n- go generics #go (2026-10-13)\n\n" +
+			"# Completed\n\n- [x] ship it +api (2026-10-13)\n\n" +
+			"# Open\n\n- [ ] write docs (since 2026-10-12)\n- [ ] follow up (since 2026-10-13)\n"},
+	}
+
+	for id, test := range tests {
+		conf.GroupBy = test.GroupBy
+		out := BuildReport(jrn, "2026-W42", conf).Export()
+		if out != test.Out {
+			t.Errorf(`Actual: "%s" Expected: "%s" Case: %q`, out, test.Out, id)
+		}
+	}
+}
+
+func TestSaveReport(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	writeJournal(t, fsys, 3)
+	var layout filesystem.Layout
+	dates, _ := layout.List(fsys)
+	report := entry.Entry{Style: entry.Pound, Sections: []entry.Section{{Title: "2019-W01", Body: "3 entries"}}}
+
+	file, err := saveReport(fsys, "2019-W01", report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != "reports/2019-W01.md" {
+		t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, file, "reports/2019-W01.md", "saved path")
+	}
+	if b, err := fsys.ReadFile(file); err != nil || string(b) != report.Export() {
+		t.Errorf(`Actual: "%s" Expected: "%s" Case: %q`, b, report.Export(), "saved report")
+	}
+	if after, _ := layout.List(fsys); !reflect.DeepEqual(after, dates) {
+		t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, after, dates, "entries after saving")
+	}
+	if _, err := saveReport(fsys, "2019-W01", report); err == nil {
+		t.Errorf("a saved report was overwritten")
+	}
+}
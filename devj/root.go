@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rootEnv is the environment variable naming the journal root, used when no -root is given.
+const rootEnv = "DEVJ_ROOT"
+
+// JournalRoot returns the root of the journal to work on: flagRoot if it is set, or else $DEVJ_ROOT,
+// or else the closest folder holding a config file, from the working directory upwards.
+func JournalRoot(flagRoot string) (string, error) {
+	root := flagRoot
+	if root == "" {
+		root = os.Getenv(rootEnv)
+	}
+	if root != "" {
+		if _, err := os.Stat(filepath.Join(root, configFile)); err != nil {
+			return "", fmt.Errorf("%s is not a journal, it has no %s file", root, configFile)
+		}
+		return filepath.Abs(root)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return FindRoot(wd)
+}
+
+// FindRoot returns the closest folder holding a config file, starting from dir and going up,
+// like git looks for a .git folder.
+func FindRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if info, err := os.Stat(filepath.Join(d, configFile)); err == nil && !info.IsDir() {
+			return d, nil
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	return "", fmt.Errorf("no journal found in %s or any folder above it; run devj inside a journal, or set -root or %s",
+		dir, rootEnv)
+}
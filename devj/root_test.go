@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devj-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "journal")
+	nested := filepath.Join(root, "2019", "01")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, configFile), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		Dir  string
+		Root string
+	}{
+		"root":    {Dir: root, Root: root},
+		"nested":  {Dir: nested, Root: root},
+		"outside": {Dir: dir},
+	}
+	for name, test := range tests {
+		got, err := FindRoot(test.Dir)
+		if got != test.Root || (err == nil) != (test.Root != "") {
+			t.Errorf("Actual: %q, %v Expected: %q Case: %q", got, err, test.Root, name)
+		}
+	}
+
+	// An explicit root wins over searching, and must be a journal.
+	t.Setenv(rootEnv, root)
+	if got, err := JournalRoot(""); got != root || err != nil {
+		t.Errorf("Actual: %q, %v Expected: %q Case: %q", got, err, root, rootEnv)
+	}
+	if _, err := JournalRoot(dir); err == nil {
+		t.Errorf("Actual: %v Expected: an error Case: %q", err, "-root without a config")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ifo/dev.journal/search"
+)
+
+// SearchCommand searches every section of the journal, and prints the matching lines.
+func SearchCommand(fs *flag.FlagSet, _ *Config) runFunc {
+	limit := fs.Int("limit", 20, "the most results to show, or 0 for all of them")
+	return func(conf *Config, args []string) error {
+		jrn, err := conf.LoadJournal(conf.FS)
+		if err != nil {
+			return err
+		}
+		results, err := search.Build(jrn).Search(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+
+		// Only highlight matches when writing to a terminal.
+		start, end := "", ""
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			start, end = "\x1b[1;31m", "\x1b[0m"
+		}
+		for i, r := range results {
+			if *limit > 0 && i == *limit {
+				fmt.Printf("... and %d more\n", len(results)-i)
+				break
+			}
+			fmt.Printf("%s %s:%d: %s\n", r.Name, r.Title, r.Line+1, strings.TrimSpace(r.Highlight(start, end)))
+		}
+		if len(results) == 0 {
+			fmt.Println("no matches")
+		}
+		return nil
+	}
+}
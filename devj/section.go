@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+const backupDir = ".devj-backup"
+
+// prefixRules collects the repeatable -by-prefix flag, given as "prefix=Title".
+type prefixRules []entry.PrefixRule
+
+func (p *prefixRules) String() string {
+	var out []string
+	for _, r := range *p {
+		out = append(out, fmt.Sprintf("%s=%s", r.Prefix, r.Title))
+	}
+	return strings.Join(out, ",")
+}
+
+func (p *prefixRules) Set(s string) error {
+	i := strings.LastIndex(s, "=")
+	if i < 1 || i == len(s)-1 {
+		return fmt.Errorf(`prefix rules must look like "prefix=Title"`)
+	}
+	*p = append(*p, entry.PrefixRule{Prefix: s[:i], Title: s[i+1:]})
+	return nil
+}
+
+// SectionCommand renames, merges or splits sections across every entry in a date range, as sub says.
+// It only shows what would change unless -apply is given, in which case the changed entries
+// and the config are backed up before being rewritten.
+func SectionCommand(sub string) flagsFunc {
+	return func(fs *flag.FlagSet, _ *Config) runFunc {
+		from := fs.String("from", "", "first date to change, as YYYY-MM-DD")
+		to := fs.String("to", "", "last date to change, as YYYY-MM-DD")
+		apply := fs.Bool("apply", false, "write the changes instead of only showing them")
+		var rules prefixRules
+		if sub == "split" {
+			fs.Var(&rules, "by-prefix", `move lines starting with prefix to Title, as "prefix=Title" (repeatable)`)
+		}
+		return func(conf *Config, args []string) error {
+			fromDate, err := parseDateFlag(*from)
+			if err != nil {
+				return usagef("%v", err)
+			}
+			toDate, err := parseDateFlag(*to)
+			if err != nil {
+				return usagef("%v", err)
+			}
+
+			public := map[string]struct{}{}
+			for k := range conf.PublicSections {
+				public[k] = struct{}{}
+			}
+			isPublic := func(title string) bool {
+				_, ok := conf.PublicSections[strings.ToLower(title)]
+				return ok
+			}
+
+			var change func(e *entry.Entry) bool
+			var summary string
+			switch sub {
+			case "rename":
+				oldTitle, newTitle := args[0], args[1]
+				summary = fmt.Sprintf("rename %s to %s", oldTitle, newTitle)
+				change = func(e *entry.Entry) bool { return e.RenameSection(oldTitle, newTitle) > 0 }
+				if isPublic(oldTitle) {
+					delete(public, strings.ToLower(oldTitle))
+					public[strings.ToLower(newTitle)] = struct{}{}
+				}
+
+			case "merge":
+				into, froms := args[0], args[1:]
+				summary = fmt.Sprintf("merge %s into %s", strings.Join(froms, ", "), into)
+				change = func(e *entry.Entry) bool { return e.MergeSections(froms, into) }
+				// The merged section is only public if everything merged into it was.
+				allPublic := isPublic(into)
+				for _, f := range froms {
+					allPublic = allPublic && isPublic(f)
+					delete(public, strings.ToLower(f))
+				}
+				if !allPublic {
+					delete(public, strings.ToLower(into))
+				}
+
+			case "split":
+				if len(rules) == 0 {
+					return usagef("split needs at least one -by-prefix rule")
+				}
+				title := args[0]
+				summary = fmt.Sprintf("split %s into %s", title, rules.String())
+				change = func(e *entry.Entry) bool { return e.SplitSection(title, rules) }
+				// New sections inherit the visibility of the section they were split from.
+				for _, r := range rules {
+					if _, listed := conf.PublicSections[strings.ToLower(r.Title)]; isPublic(title) && !listed {
+						public[strings.ToLower(r.Title)] = struct{}{}
+					}
+				}
+			}
+
+			// The entries are read under the lock too, so nothing written in between is overwritten.
+			if *apply {
+				unlock, err := filesystem.Lock(conf.FS, ".", filesystem.LockWait)
+				if err != nil {
+					return err
+				}
+				defer unlock()
+			}
+
+			dates, err := conf.Layout.List(conf.FS)
+			if err != nil {
+				return err
+			}
+			changed := map[entry.Date]entry.Entry{}
+			total := 0
+			for _, date := range dates {
+				if !fromDate.IsZero() && date.Before(fromDate) || !toDate.IsZero() && date.After(toDate) {
+					continue
+				}
+				total++
+				raw, err := conf.FS.ReadFile(conf.Layout.Path(date))
+				if err != nil {
+					return err
+				}
+				e, err := entry.Import(string(raw))
+				if err != nil {
+					return fmt.Errorf("%s: %v", date, err)
+				}
+				if change(&e) {
+					changed[date] = e
+					fmt.Printf("%s: %s\n", date, summary)
+				}
+			}
+
+			publicChanged := !reflect.DeepEqual(public, conf.PublicSections)
+			if publicChanged {
+				fmt.Printf("public_sections: %s -> %s\n", sectionList(conf.PublicSections), sectionList(public))
+			}
+			if !*apply {
+				fmt.Printf("%d of %d entries would change; run again with -apply to write them\n", len(changed), total)
+				return nil
+			}
+			if len(changed) == 0 && !publicChanged {
+				fmt.Println("nothing to change")
+				return nil
+			}
+
+			backup := path.Join(backupDir, time.Now().Format("20060102-150405"))
+			if err := filesystem.BackupFile(conf.FS, configFile, backup); err != nil {
+				return err
+			}
+			for date, e := range changed {
+				if err := filesystem.OverwriteFile(conf.FS, conf.Layout.Path(date), []byte(e.Export()), backup); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("backup written to %s\n", backup)
+			if publicChanged {
+				if err := WritePublicSections(conf.FS, configFile, public); err != nil {
+					return err
+				}
+				conf.PublicSections = public
+			}
+			fmt.Printf("%d of %d entries changed\n", len(changed), total)
+			return nil
+		}
+	}
+}
+
+func sectionList(sections map[string]struct{}) string {
+	var out []string
+	for k := range sections {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return "[" + strings.Join(out, ", ") + "]"
+}
+
+// parseDateFlag parses the value of a date flag, where "" stands for no date.
+func parseDateFlag(s string) (entry.Date, error) {
+	if s == "" {
+		return entry.Date{}, nil
+	}
+	return entry.ParseDate(s)
+}
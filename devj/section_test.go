@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ifo/dev.journal/filesystem"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+// runSection runs devj section sub on conf, with args as given on the command line.
+func runSection(conf *Config, sub string, args ...string) error {
+	fs := flag.NewFlagSet("devj section "+sub, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	run := SectionCommand(sub)(fs, conf)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return run(conf, fs.Args())
+}
+
+func TestSectionCommand(t *testing.T) {
+	const config = `{"public_sections": {"do": true}}`
+	// written is the entry of day i as writeJournal writes it.
+	written := func(i int) string {
+		return fmt.Sprintf("# Do\n\nthings %d\n\n# Learn\n\nsee notes-%d.txt\n", i, i)
+	}
+
+	tests := map[string]struct {
+		Sub  string
+		Args []string
+		// Entries are the expected entries of the three days, where "" is the entry as written.
+		Entries [3]string
+		Public  []string
+		// Backup lists the files expected in the backup folder, without the folder of the run.
+		Backup []string
+	}{
+		"rename, dry run": {
+			Sub: "rename", Args: []string{"Do", "Tasks"},
+			Public: []string{"do"}},
+		"rename": {
+			Sub: "rename", Args: []string{"-from", "2019-01-02", "-apply", "Do", "Tasks"},
+			Entries: [3]string{1: "# Tasks\n\nthings 1\n\n# Learn\n\nsee notes-1.txt\n",
+				2: "# Tasks\n\nthings 2\n\n# Learn\n\nsee notes-2.txt\n"},
+			Public: []string{"tasks"},
+			Backup: []string{".devj", "2019-01-02/2019-01-02.md", "2019-01-03/2019-01-03.md"}},
+		"merge": {
+			Sub: "merge", Args: []string{"-to", "2019-01-01", "-apply", "Do", "Learn"},
+			Entries: [3]string{0: "# Do\n\nthings 0\n\nsee notes-0.txt\n"},
+			Public:  []string{},
+			Backup:  []string{".devj", "2019-01-01/2019-01-01.md"}},
+		"split": {
+			Sub: "split", Args: []string{"-from", "2019-01-02", "-to", "2019-01-02", "-apply", "-by-prefix", "things=Things", "Do"},
+			Entries: [3]string{1: "# Things\n\nthings 1\n\n# Learn\n\nsee notes-1.txt\n"},
+			Public:  []string{"do", "things"},
+			Backup:  []string{".devj", "2019-01-02/2019-01-02.md"}},
+		"split where a rule matches": {
+			Sub: "split", Args: []string{"-apply", "-by-prefix", "things 1=Things", "Do"},
+			Entries: [3]string{1: "# Things\n\nthings 1\n\n# Learn\n\nsee notes-1.txt\n"},
+			Public:  []string{"do", "things"},
+			Backup:  []string{".devj", "2019-01-02/2019-01-02.md"}},
+		"nothing matches": {
+			Sub: "rename", Args: []string{"-apply", "Blockers", "Stuck"},
+			Public: []string{"do"}},
+	}
+
+	for id, test := range tests {
+		fsys := fstest.NewMemFS()
+		writeJournal(t, fsys, 3)
+		fsys.WriteFile(configFile, []byte(config))
+		conf, err := ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := runSection(conf, test.Sub, test.Args...); err != nil {
+			t.Errorf("Case %q: %v", id, err)
+			continue
+		}
+
+		for i, date := range []string{"2019-01-01", "2019-01-02", "2019-01-03"} {
+			expected := test.Entries[i]
+			if expected == "" {
+				expected = written(i)
+			}
+			if raw, _ := fsys.ReadFile(date + "/" + date + ".md"); string(raw) != expected {
+				t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, string(raw), expected, id)
+			}
+		}
+
+		conf, err = ReadConfig(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		public := []string{}
+		for k := range conf.PublicSections {
+			public = append(public, k)
+		}
+		sort.Strings(public)
+		if !reflect.DeepEqual(public, test.Public) {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, public, test.Public, id)
+		}
+
+		var backup []string
+		for _, name := range fsys.Names() {
+			if strings.HasPrefix(name, backupDir+"/") {
+				// Drop the backup folder and the folder of the run in it.
+				backup = append(backup, strings.SplitN(name, "/", 3)[2])
+			}
+		}
+		if !reflect.DeepEqual(backup, test.Backup) {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, backup, test.Backup, id)
+		}
+		// The backup holds the entries as they were before the run.
+		runs, _ := filesystem.ListDirs(fsys, backupDir)
+		for _, name := range backup {
+			if name == configFile {
+				continue
+			}
+			saved, _ := fsys.ReadFile(path.Join(backupDir, runs[0], name))
+			if expected := written(int(name[len("2019-01-0")] - '1')); string(saved) != expected {
+				t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, string(saved), expected, id)
+			}
+		}
+	}
+}
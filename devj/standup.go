@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+// Standup is what devj standup prints.
+type Standup struct {
+	Date          string   `json:"date"`
+	YesterdayDate string   `json:"yesterday_date,omitempty"`
+	Yesterday     []string `json:"yesterday"`
+	Today         []string `json:"today"`
+	Blockers      []string `json:"blockers"`
+}
+
+// StandupCommand prints a standup from the previous working day's entry and today's entry.
+// Only public sections are read, so the standup is safe to share.
+func StandupCommand(fs *flag.FlagSet, _ *Config) runFunc {
+	format := fs.String("format", "markdown", `output format: "markdown", "plain" or "json"`)
+	return func(conf *Config, args []string) error {
+		if *format != "markdown" && *format != "plain" && *format != "json" {
+			return usagef(`unknown format %q, use "markdown", "plain" or "json"`, *format)
+		}
+
+		for _, sections := range [][]string{conf.Standup.Yesterday, conf.Standup.Today, conf.Standup.Blockers} {
+			for _, s := range sections {
+				if _, ok := conf.PublicSections[strings.ToLower(s)]; !ok {
+					fmt.Fprintf(os.Stderr, "section %q is not public, so it is left out of the standup\n", s)
+				}
+			}
+		}
+
+		today := conf.Today()
+		yesterday, _ := conf.Layout.Previous(conf.FS, today)
+
+		var prev, cur *entry.Entry
+		var err error
+		if !yesterday.IsZero() {
+			if prev, err = readPublicEntry(conf, yesterday); err != nil {
+				return err
+			}
+		}
+		if conf.Layout.Exists(conf.FS, today) {
+			if cur, err = readPublicEntry(conf, today); err != nil {
+				return err
+			}
+		}
+
+		s := BuildStandup(prev, cur, conf.Standup)
+		s.Date, s.YesterdayDate = today.String(), yesterday.String()
+		switch *format {
+		case "json":
+			bts, err := json.MarshalIndent(s, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(bts))
+		case "plain":
+			fmt.Print(s.format("%s:\n", "  %s\n", "  nothing\n"))
+		default:
+			fmt.Print(s.format("**%s**\n", "- %s\n", "- Nothing\n"))
+		}
+		return nil
+	}
+}
+
+// BuildStandup gathers the standup from the entries of the previous working day and of today,
+// either of which may be nil.
+func BuildStandup(prev, today *entry.Entry, conf StandupConfig) Standup {
+	s := Standup{Yesterday: []string{}, Today: []string{}, Blockers: []string{}}
+	done := map[string]struct{}{}
+
+	if prev != nil {
+		sectionItems(*prev, conf.Yesterday, func(text string, item *entry.ListItem, top bool) {
+			done[strings.ToLower(text)] = struct{}{}
+			if item.Task && item.Done || !item.Task && top {
+				s.Yesterday = appendNew(s.Yesterday, text)
+			}
+		})
+	}
+	if today != nil {
+		sectionItems(*today, conf.Today, func(text string, item *entry.ListItem, top bool) {
+			_, before := done[strings.ToLower(text)]
+			if item.Task && !item.Done || !item.Task && top && !before {
+				s.Today = appendNew(s.Today, text)
+			}
+		})
+		sectionItems(*today, conf.Blockers, func(text string, item *entry.ListItem, top bool) {
+			if !item.Done {
+				s.Blockers = appendNew(s.Blockers, text)
+			}
+		})
+	}
+
+	// Anything still open and tagged #blocked is a blocker, wherever it was written.
+	for _, e := range []*entry.Entry{prev, today} {
+		if e == nil {
+			continue
+		}
+		titles := append(append([]string{}, conf.Yesterday...), conf.Today...)
+		sectionItems(*e, titles, func(text string, item *entry.ListItem, top bool) {
+			for _, tag := range entry.FindTags(text) {
+				if tag == "blocked" && !item.Done {
+					s.Blockers = appendNew(s.Blockers, text)
+				}
+			}
+		})
+	}
+	return s
+}
+
+func (s Standup) format(heading, item, empty string) string {
+	out := ""
+	for _, part := range []struct {
+		title string
+		items []string
+	}{{"Yesterday", s.Yesterday}, {"Today", s.Today}, {"Blockers", s.Blockers}} {
+		if out != "" {
+			out += "\n"
+		}
+		out += fmt.Sprintf(heading, part.title)
+		for _, it := range part.items {
+			out += fmt.Sprintf(item, it)
+		}
+		if len(part.items) == 0 {
+			out += empty
+		}
+	}
+	return out
+}
+
+// readPublicEntry reads the public sections of the entry for date. Public sections are kept
+// in plain text, so the key is only asked for if the entry is encrypted as a whole.
+func readPublicEntry(conf *Config, date entry.Date) (*entry.Entry, error) {
+	raw, err := conf.FS.ReadFile(conf.Layout.Path(date))
+	if err != nil {
+		return nil, err
+	}
+	e, err := entry.ImportPublic(string(raw), conf.PublicSections)
+	if errors.Is(err, entry.ErrSealed) {
+		e, err = conf.importSealed(string(raw))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", conf.Layout.Path(date), err)
+	}
+	return &e, nil
+}
+
+// sectionItems calls fn on every list item of the sections of e titled one of titles,
+// with the item's text on a single line.
+func sectionItems(e entry.Entry, titles []string, fn func(text string, item *entry.ListItem, top bool)) {
+	for _, s := range e.Sections {
+		if reportSection(ReportConfig{Sections: titles}, s.Title) == "" {
+			continue
+		}
+		for _, b := range s.Blocks() {
+			if b.List == nil {
+				continue
+			}
+			walkItems(b.List, func(item *entry.ListItem, top bool) {
+				fn(strings.Join(strings.Fields(item.Text), " "), item, top)
+			})
+		}
+	}
+}
+
+func appendNew(list []string, s string) []string {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+func TestBuildStandup(t *testing.T) {
+	prev := &entry.Entry{Sections: []entry.Section{
+		{Title: "Do", Body: "- fixed the pager\n  - paged twice\n- [x] ship it\n- [ ] write docs\n- [ ] get access #blocked"},
+	}}
+	today := &entry.Entry{Sections: []entry.Section{
+		{Title: "do", Body: "- fixed the pager\n- pair with Sam\n- [ ] write docs\n- [x] ship it\n- [ ] get access #blocked"},
+		{Title: "Blockers", Body: "- waiting on review\n- [x] sorted out"},
+	}}
+	conf := StandupConfig{Yesterday: []string{"Do"}, Today: []string{"Do"}, Blockers: []string{"Blockers"}}
+
+	tests := map[string]struct {
+		Prev, Today *entry.Entry
+		Out         Standup
+	}{
+		"both": {Prev: prev, Today: today, Out: Standup{
+			Yesterday: []string{"fixed the pager", "ship it"},
+			Today:     []string{"pair with Sam", "write docs", "get access #blocked"},
+			Blockers:  []string{"waiting on review", "get access #blocked"}}},
+		"no today": {Prev: prev, Out: Standup{
+			Yesterday: []string{"fixed the pager", "ship it"},
+			Today:     []string{},
+			Blockers:  []string{"get access #blocked"}}},
+		"no yesterday": {Today: today, Out: Standup{
+			Yesterday: []string{},
+			Today:     []string{"fixed the pager", "pair with Sam", "write docs", "get access #blocked"},
+			Blockers:  []string{"waiting on review", "get access #blocked"}}},
+	}
+
+	for id, test := range tests {
+		out := BuildStandup(test.Prev, test.Today, conf)
+		if !reflect.DeepEqual(out, test.Out) {
+			t.Errorf("Actual: %+v Expected: %+v Case: %q", out, test.Out, id)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// Stats describes how, and how much, the journal has been written in.
+type Stats struct {
+	Entries       int `json:"entries"`
+	CurrentStreak int `json:"current_streak"`
+	LongestStreak int `json:"longest_streak"`
+	// MissedDays are the working days since the first entry that have no entry.
+	MissedDays []string `json:"missed_days"`
+	// Weeks are the ISO weeks shown, oldest first. EntriesPerWeek and the counts of SectionWords follow them.
+	Weeks          []string       `json:"weeks"`
+	EntriesPerWeek []int          `json:"entries_per_week"`
+	SectionWords   []SectionWords `json:"section_words"`
+	// DayWords is the number of words written on each day with an entry in the weeks shown.
+	DayWords    map[string]int `json:"day_words"`
+	Tags        []TagCount     `json:"tags"`
+	Attachments []Attachment   `json:"attachments"`
+}
+
+// SectionWords is the number of words written in a section during each week.
+type SectionWords struct {
+	Section string `json:"section"`
+	Words   []int  `json:"words"`
+}
+
+// TagCount is the number of entries using a tag.
+type TagCount struct {
+	Tag     string `json:"tag"`
+	Entries int    `json:"entries"`
+}
+
+// Attachment is a file kept in a day folder next to its entry.
+type Attachment struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// StatsCommand prints writing streaks and other statistics about the whole journal, private sections included.
+func StatsCommand(fs *flag.FlagSet, _ *Config) runFunc {
+	weeks := fs.Int("weeks", 12, "the number of weeks to show, up to and including this one")
+	top := fs.Int("top", 5, "the number of tags and attachments to list")
+	asJSON := fs.Bool("json", false, "print the statistics as JSON")
+	return func(conf *Config, args []string) error {
+		if *weeks < 1 {
+			return usagef("-weeks must be at least 1")
+		}
+
+		jrn, err := conf.LoadJournal(conf.FS)
+		if err != nil {
+			return err
+		}
+		stats := BuildStats(jrn, conf.Today(), *weeks, *top)
+		if stats.Attachments, err = biggestAttachments(conf.Layout, jrn, conf.FS, *top); err != nil {
+			return err
+		}
+
+		if *asJSON {
+			bts, err := json.MarshalIndent(stats, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(bts))
+			return nil
+		}
+		fmt.Print(stats.String())
+		return nil
+	}
+}
+
+// BuildStats computes the statistics of jrn as of today, over the last weeks ISO weeks.
+// Only the top most used tags are kept. Attachments are left for the caller to fill in,
+// since their sizes are not part of the journal.
+func BuildStats(jrn *entry.Journal, today entry.Date, weeks, top int) Stats {
+	names := jrn.Names()
+	stats := Stats{
+		Entries:     len(names),
+		MissedDays:  []string{},
+		DayWords:    map[string]int{},
+		Tags:        []TagCount{},
+		Attachments: []Attachment{},
+	}
+
+	written := map[entry.Date]struct{}{}
+	var first entry.Date
+	for _, name := range names {
+		if d, err := name.Date(); err == nil {
+			written[d] = struct{}{}
+			if first.IsZero() {
+				first = d
+			}
+		}
+	}
+	if !first.IsZero() {
+		stats.CurrentStreak, stats.LongestStreak, stats.MissedDays = streaks(written, first, today)
+	}
+
+	// Weeks start on the Monday of the first week shown.
+	start := today.StartOfWeek().AddDays(-(weeks - 1) * 7)
+	for i := 0; i < weeks; i++ {
+		stats.Weeks = append(stats.Weeks, start.AddDays(i*7).Week())
+	}
+	stats.EntriesPerWeek = make([]int, weeks)
+
+	sectionWords := map[string][]int{}
+	var sections []string
+	tags := map[string]int{}
+	for _, e := range jrn.Ordered() {
+		for _, tag := range e.Tags() {
+			tags[tag]++
+		}
+		d, err := e.Name.Date()
+		if err != nil || d.Before(start) || d.After(today) {
+			continue
+		}
+		week := d.DaysSince(start) / 7
+		stats.EntriesPerWeek[week]++
+		for _, s := range e.Sections {
+			key := strings.ToLower(s.Title)
+			if _, ok := sectionWords[key]; !ok {
+				sectionWords[key] = make([]int, weeks)
+				sections = append(sections, s.Title)
+			}
+			words := countWords(s.Body)
+			sectionWords[key][week] += words
+			stats.DayWords[string(e.Name)] += words
+		}
+	}
+	for _, s := range sections {
+		stats.SectionWords = append(stats.SectionWords, SectionWords{Section: s, Words: sectionWords[strings.ToLower(s)]})
+	}
+
+	for tag, n := range tags {
+		stats.Tags = append(stats.Tags, TagCount{Tag: tag, Entries: n})
+	}
+	sort.Slice(stats.Tags, func(i, k int) bool {
+		if stats.Tags[i].Entries != stats.Tags[k].Entries {
+			return stats.Tags[i].Entries > stats.Tags[k].Entries
+		}
+		return stats.Tags[i].Tag < stats.Tags[k].Tag
+	})
+	if len(stats.Tags) > top {
+		stats.Tags = stats.Tags[:top]
+	}
+	return stats
+}
+
+// streaks walks every day from first to today. Weekends never break a streak, but count towards it
+// when written on. Today only breaks the current streak once it is over.
+func streaks(written map[entry.Date]struct{}, first, today entry.Date) (current, longest int, missed []string) {
+	missed = []string{}
+	run := 0
+	for d := first; !d.After(today); d = d.AddDays(1) {
+		if _, ok := written[d]; ok {
+			run++
+		} else if !d.IsWeekend() && d != today {
+			missed = append(missed, d.String())
+			run = 0
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+	return run, longest, missed
+}
+
+// biggestAttachments lists the top largest attachments of the entries in jrn, largest first.
+func biggestAttachments(layout filesystem.Layout, jrn *entry.Journal, fsys filesystem.FS, top int) ([]Attachment, error) {
+	attachments := []Attachment{}
+	for _, e := range jrn.Ordered() {
+		date, err := e.Name.Date()
+		if err != nil {
+			return nil, err
+		}
+		for name := range e.FileNames {
+			file := path.Join(layout.Dir(date), name)
+			info, err := fsys.Stat(file)
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				continue
+			}
+			attachments = append(attachments, Attachment{Path: file, Size: info.Size()})
+		}
+	}
+	sort.Slice(attachments, func(i, k int) bool {
+		if attachments[i].Size != attachments[k].Size {
+			return attachments[i].Size > attachments[k].Size
+		}
+		return attachments[i].Path < attachments[k].Path
+	})
+	if len(attachments) > top {
+		attachments = attachments[:top]
+	}
+	return attachments, nil
+}
+
+func (s Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d entries, current streak %s, longest streak %s\n",
+		s.Entries, plural(s.CurrentStreak, "day"), plural(s.LongestStreak, "day"))
+	fmt.Fprintf(&b, "%s missed", plural(len(s.MissedDays), "working day"))
+	if n := len(s.MissedDays); n > 0 {
+		recent := s.MissedDays
+		if n > 3 {
+			recent = recent[n-3:]
+		}
+		fmt.Fprintf(&b, ", most recently %s", strings.Join(recent, ", "))
+	}
+	fmt.Fprintf(&b, "\n\n")
+
+	if len(s.Weeks) > 0 {
+		fmt.Fprintf(&b, "%s to %s\n\n", s.Weeks[0], s.Weeks[len(s.Weeks)-1])
+	}
+	width := len("entries")
+	for _, sw := range s.SectionWords {
+		if len(sw.Section) > width {
+			width = len(sw.Section)
+		}
+	}
+	fmt.Fprintf(&b, "%-*s  %s  %d\n", width, "entries", Sparkline(s.EntriesPerWeek), sum(s.EntriesPerWeek))
+	for _, sw := range s.SectionWords {
+		fmt.Fprintf(&b, "%-*s  %s  %d words\n", width, sw.Section, Sparkline(sw.Words), sum(sw.Words))
+	}
+	fmt.Fprintf(&b, "\n%s\n", Heatmap(s.Weeks, s.DayWords))
+
+	if len(s.Tags) > 0 {
+		fmt.Fprintf(&b, "\nmost used tags\n")
+		for _, t := range s.Tags {
+			fmt.Fprintf(&b, "  #%s %d\n", t.Tag, t.Entries)
+		}
+	}
+	if len(s.Attachments) > 0 {
+		fmt.Fprintf(&b, "\nbiggest attachments\n")
+		for _, a := range s.Attachments {
+			fmt.Fprintf(&b, "  %s %s\n", humanSize(a.Size), a.Path)
+		}
+	}
+	return b.String()
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws counts as a line of bars, scaled so the largest count is the tallest bar.
+func Sparkline(counts []int) string {
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	out := make([]rune, len(counts))
+	for i, c := range counts {
+		out[i] = sparks[0]
+		if max > 0 {
+			out[i] = sparks[c*(len(sparks)-1)/max]
+		}
+	}
+	return string(out)
+}
+
+var heat = []rune("·░▒▓█")
+
+// Heatmap draws a row for each day of the week and a column for each of weeks, shading
+// the days written on by how many words were written, relative to the busiest day.
+func Heatmap(weeks []string, dayWords map[string]int) string {
+	max := 0
+	for _, w := range dayWords {
+		if w > max {
+			max = w
+		}
+	}
+	var rows []string
+	for i, name := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+		row := []rune(name + " ")
+		for _, week := range weeks {
+			monday, _, err := entry.ParseWeek(week)
+			if err != nil {
+				continue
+			}
+			words, ok := dayWords[monday.AddDays(i).String()]
+			switch {
+			case !ok:
+				row = append(row, heat[0])
+			case max == 0:
+				row = append(row, heat[1])
+			default:
+				row = append(row, heat[1+words*(len(heat)-2)/max])
+			}
+		}
+		rows = append(rows, string(row))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// countWords counts the words of text, leaving out list markers and other punctuation.
+func countWords(text string) int {
+	n := 0
+	for _, f := range strings.Fields(text) {
+		if strings.IndexFunc(f, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) != -1 {
+			n++
+		}
+	}
+	return n
+}
+
+func sum(counts []int) int {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	return total
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+func humanSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	f := float64(size)
+	i := 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", f, units[i])
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+func TestBuildStats(t *testing.T) {
+	jrn := entry.NewJournal()
+	for _, name := range []entry.EntryName{"2026-10-01", "2026-10-02", "2026-10-06", "2026-10-07", "2026-10-09",
+		"2026-10-10", "2026-10-12", "2026-10-13", "2026-10-14"} {
+		jrn.Add(entry.Entry{Name: name, Sections: []entry.Section{{Title: "Do", Body: "- one two #go"}}})
+	}
+	jrn.Add(entry.Entry{Name: "2026-10-16", Sections: []entry.Section{
+		{Title: "do", Body: "- three #oncall"}, {Title: "Learn", Body: "four five six #go"}}})
+	today, _ := entry.ParseDate("2026-10-19")
+
+	stats := BuildStats(jrn, today, 2, 5)
+	expected := Stats{
+		Entries:        10,
+		CurrentStreak:  1,
+		LongestStreak:  5,
+		MissedDays:     []string{"2026-10-05", "2026-10-08", "2026-10-15"},
+		Weeks:          []string{"2026-W42", "2026-W43"},
+		EntriesPerWeek: []int{4, 0},
+		SectionWords: []SectionWords{
+			{Section: "Do", Words: []int{11, 0}},
+			{Section: "Learn", Words: []int{4, 0}},
+		},
+		DayWords:    map[string]int{"2026-10-12": 3, "2026-10-13": 3, "2026-10-14": 3, "2026-10-16": 6},
+		Tags:        []TagCount{{Tag: "go", Entries: 10}, {Tag: "oncall", Entries: 1}},
+		Attachments: []Attachment{},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Actual: %#v Expected: %#v", stats, expected)
+	}
+
+	// Today does not break the streak until it is over, and weekends never do.
+	jrn.Add(entry.Entry{Name: "2026-10-15"})
+	if stats := BuildStats(jrn, today, 1, 1); stats.CurrentStreak != 7 || stats.LongestStreak != 7 ||
+		len(stats.Tags) != 1 {
+		t.Errorf("Actual: %#v", stats)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := map[string]struct {
+		Counts []int
+		Out    string
+	}{
+		"empty":  {Counts: nil, Out: ""},
+		"zeroes": {Counts: []int{0, 0}, Out: "▁▁"},
+		"scaled": {Counts: []int{0, 7, 14, 3}, Out: "▁▄█▂"},
+	}
+
+	for id, test := range tests {
+		if out := Sparkline(test.Counts); out != test.Out {
+			t.Errorf(`Actual: "%s" Expected: "%s" Case: %q`, out, test.Out, id)
+		}
+	}
+}
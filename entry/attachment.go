@@ -0,0 +1,145 @@
+package entry
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// imageExts are the attachments Link shows inline.
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true}
+
+// Link is a markdown link to the attachment named name, or an image showing it if it is one.
+func Link(name string) string {
+	target := name
+	if strings.ContainsAny(name, " ()") {
+		target = "<" + name + ">"
+	}
+	link := "[" + name + "](" + target + ")"
+	if imageExts[strings.ToLower(path.Ext(name))] {
+		return "!" + link
+	}
+	return link
+}
+
+// FileFilter picks the attachments that are exported.
+type FileFilter struct {
+	// MaxSize is the size in bytes of the largest file exported. Zero exports files of any size.
+	MaxSize int64 `json:"max_size"`
+	// Ignore are patterns, as path.Match takes them, of the names of files that are never exported, such as "*.log".
+	Ignore []string `json:"ignore"`
+}
+
+// Skips returns why the file named name, of size bytes, is not exported, or "" if it is.
+func (f FileFilter) Skips(name string, size int64) string {
+	for _, pattern := range f.Ignore {
+		if ok, _ := path.Match(pattern, name); ok {
+			return "ignored as " + pattern
+		}
+	}
+	if f.MaxSize > 0 && size > f.MaxSize {
+		return fmt.Sprintf("larger than %d bytes", f.MaxSize)
+	}
+	return ""
+}
+
+// AddLink adds a list item linking the attachment named name to the end of the section titled title
+// (ignoring case). The section is added at the end of the entry if there is none.
+func (e *Entry) AddLink(title, name string) {
+	item := "- " + Link(name)
+	for i, s := range e.Sections {
+		if !strings.EqualFold(s.Title, title) {
+			continue
+		}
+		switch lines := strings.Split(s.Body, "\n"); {
+		case s.Body == "":
+			e.Sections[i].Body = item
+		case strings.HasPrefix(lines[len(lines)-1], "- "):
+			e.Sections[i].Body += "\n" + item
+		default:
+			e.Sections[i].Body += "\n\n" + item
+		}
+		return
+	}
+	e.Sections = append(e.Sections, Section{Title: title, Body: item})
+}
+
+// Mentions returns the titles of the sections mentioning the attachment named name.
+func (e Entry) Mentions(name string) []string {
+	var titles []string
+	for _, s := range e.Sections {
+		if len(nameIndexes(s.Body, name)) > 0 {
+			titles = append(titles, s.Title)
+		}
+	}
+	return titles
+}
+
+// RenameFile changes every mention of the attachment named from into to, and returns how many it changed.
+// Only whole names are changed, so renaming "a.png" leaves "data.png" alone.
+func (e *Entry) RenameFile(from, to string) int {
+	renamed := 0
+	for i, s := range e.Sections {
+		idx := nameIndexes(s.Body, from)
+		// Replace from the end, so the earlier indexes still hold.
+		for k := len(idx) - 1; k >= 0; k-- {
+			s.Body = s.Body[:idx[k]] + to + s.Body[idx[k]+len(from):]
+		}
+		// Link targets with spaces must be wrapped in <>.
+		if strings.ContainsAny(to, " ()") {
+			s.Body = strings.Replace(s.Body, "]("+to+")", "](<"+to+">)", -1)
+		}
+		e.Sections[i].Body = s.Body
+		renamed += len(idx)
+	}
+	return renamed
+}
+
+// RemoveLinks removes the lines that are only a link to the attachment named name, such as those AddLink adds,
+// and returns how many it removed. Other mentions of it are left alone.
+func (e *Entry) RemoveLinks(name string) int {
+	re := regexp.MustCompile(`^\s*([-*+]\s+)?!?\[[^\]]*\]\(<?` + regexp.QuoteMeta(name) + `>?\)\s*$`)
+	removed := 0
+	for i, s := range e.Sections {
+		var kept []string
+		for _, l := range strings.Split(s.Body, "\n") {
+			if re.MatchString(l) {
+				removed++
+				continue
+			}
+			kept = append(kept, l)
+		}
+		e.Sections[i].Body = strings.TrimSpace(strings.Join(kept, "\n"))
+	}
+	return removed
+}
+
+// nameIndexes finds where body mentions the file named name, as a whole name rather than part of a longer one.
+func nameIndexes(body, name string) []int {
+	var idx []int
+	if name == "" {
+		return nil
+	}
+	for at := 0; ; {
+		i := strings.Index(body[at:], name)
+		if i < 0 {
+			return idx
+		}
+		i += at
+		end := i + len(name)
+		before := i == 0 || !isNameRune(rune(body[i-1])) && body[i-1] != '.'
+		// A full stop ends a sentence, unless more of a name follows it.
+		after := end == len(body) || !isNameRune(rune(body[end])) &&
+			!(body[end] == '.' && end+1 < len(body) && isNameRune(rune(body[end+1])))
+		if before && after {
+			idx = append(idx, i)
+		}
+		at = i + 1
+	}
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
@@ -0,0 +1,126 @@
+package entry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLink(t *testing.T) {
+	tests := map[string]string{
+		"notes.txt":   "[notes.txt](notes.txt)",
+		"diagram.PNG": "![diagram.PNG](diagram.PNG)",
+		"my notes.md": "[my notes.md](<my notes.md>)",
+	}
+
+	for name, expected := range tests {
+		if link := Link(name); link != expected {
+			t.Errorf(testFail, link, expected, name)
+		}
+	}
+}
+
+func TestEntry_AddLink(t *testing.T) {
+	tests := map[string]struct {
+		E     Entry
+		Title string
+		Out   Entry
+	}{
+		"empty section": {
+			E:     Entry{Sections: []Section{{Title: "Do"}, {Title: "Learn"}}},
+			Title: "learn",
+			Out:   Entry{Sections: []Section{{Title: "Do"}, {Title: "Learn", Body: "- [a.txt](a.txt)"}}}},
+		"after a list": {
+			E:     Entry{Sections: []Section{{Title: "Learn", Body: "- b"}}},
+			Title: "Learn",
+			Out:   Entry{Sections: []Section{{Title: "Learn", Body: "- b\n- [a.txt](a.txt)"}}}},
+		"after a paragraph": {
+			E:     Entry{Sections: []Section{{Title: "Learn", Body: "b"}}},
+			Title: "Learn",
+			Out:   Entry{Sections: []Section{{Title: "Learn", Body: "b\n\n- [a.txt](a.txt)"}}}},
+		"missing section": {
+			E:     Entry{Sections: []Section{{Title: "Do"}}},
+			Title: "Learn",
+			Out:   Entry{Sections: []Section{{Title: "Do"}, {Title: "Learn", Body: "- [a.txt](a.txt)"}}}},
+	}
+
+	for id, test := range tests {
+		test.E.AddLink(test.Title, "a.txt")
+		if !reflect.DeepEqual(test.E, test.Out) {
+			t.Errorf(testFail, test.E, test.Out, id)
+		}
+	}
+}
+
+func TestEntry_RenameFile(t *testing.T) {
+	tests := map[string]struct {
+		Body    string
+		From    string
+		To      string
+		Out     string
+		Renamed int
+	}{
+		"link": {
+			Body: "- ![a.png](a.png)", From: "a.png", To: "b.png",
+			Out: "- ![b.png](b.png)", Renamed: 2},
+		"whole names only": {
+			Body: "data.png, a.png.bak and a.pngs, but a.png.", From: "a.png", To: "b.png",
+			Out: "data.png, a.png.bak and a.pngs, but b.png.", Renamed: 1},
+		"to a name with spaces": {
+			Body: "- [a.txt](a.txt)", From: "a.txt", To: "my a.txt",
+			Out: "- [my a.txt](<my a.txt>)", Renamed: 2},
+		"not mentioned": {
+			Body: "nothing", From: "a.txt", To: "b.txt",
+			Out: "nothing", Renamed: 0},
+	}
+
+	for id, test := range tests {
+		e := Entry{Sections: []Section{{Title: "Learn", Body: test.Body}}}
+		renamed := e.RenameFile(test.From, test.To)
+		if e.Sections[0].Body != test.Out {
+			t.Errorf(testFail, e.Sections[0].Body, test.Out, id)
+		}
+		if renamed != test.Renamed {
+			t.Errorf(testFail, renamed, test.Renamed, id)
+		}
+	}
+}
+
+func TestEntry_RemoveLinks(t *testing.T) {
+	e := Entry{Sections: []Section{
+		{Title: "Do", Body: "see a.png for more"},
+		{Title: "Learn", Body: "- b\n- ![a.png](a.png)\n* [the diagram](<a.png>)"},
+	}}
+	if removed := e.RemoveLinks("a.png"); removed != 2 {
+		t.Errorf(testFail, removed, 2, "removed")
+	}
+	expected := []Section{{Title: "Do", Body: "see a.png for more"}, {Title: "Learn", Body: "- b"}}
+	if !reflect.DeepEqual(e.Sections, expected) {
+		t.Errorf(testFail, e.Sections, expected, "sections")
+	}
+	if mentions := e.Mentions("a.png"); !reflect.DeepEqual(mentions, []string{"Do"}) {
+		t.Errorf(testFail, mentions, []string{"Do"}, "mentions")
+	}
+}
+
+func TestFileFilter_Skips(t *testing.T) {
+	filter := FileFilter{MaxSize: 100, Ignore: []string{"*.log", "core.*"}}
+	tests := map[string]struct {
+		Name string
+		Size int64
+		Out  string
+	}{
+		"small":   {Name: "a.png", Size: 100, Out: ""},
+		"large":   {Name: "a.png", Size: 101, Out: "larger than 100 bytes"},
+		"ignored": {Name: "server.log", Size: 1, Out: "ignored as *.log"},
+		"prefix":  {Name: "core.123", Size: 1, Out: "ignored as core.*"},
+	}
+
+	for id, test := range tests {
+		if out := filter.Skips(test.Name, test.Size); out != test.Out {
+			t.Errorf(testFail, out, test.Out, id)
+		}
+	}
+	if out := (FileFilter{}).Skips("huge.log", 1<<40); out != "" {
+		t.Errorf(testFail, out, "", "zero filter")
+	}
+}
@@ -0,0 +1,459 @@
+package entry
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type BlockKind int
+
+const (
+	Paragraph BlockKind = iota
+	BulletList
+	NumberedList
+	CodeFence
+	Quote
+	Table
+)
+
+var (
+	itemRegex      = regexp.MustCompile(`^([ \t]*)([-*+]|\d{1,9}[.)])([ \t]+|$)(.*)$`)
+	fenceRegex     = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	tableDelimiter = regexp.MustCompile(`^ {0,3}\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// Block is a single markdown block of a section body, such as a paragraph, a list or a code fence.
+type Block struct {
+	Kind BlockKind
+	// Lines are the lines of the block as written.
+	// They are exported as they are, unless the block is a list and its List has been changed.
+	Lines []string
+	// List holds the items of BulletList and NumberedList blocks, and is nil otherwise.
+	List *List
+
+	// blank is the number of blank lines before the block, and is only kept for parsed blocks.
+	blank  int
+	parsed bool
+}
+
+// List is a bullet or numbered list, which may be nested in a ListItem.
+type List struct {
+	Numbered bool
+	Items    []*ListItem
+
+	src *listSource
+}
+
+// ListItem is a single item of a List.
+// Text does not include the list marker or the task box, and continuation lines are joined with "\n".
+type ListItem struct {
+	Text     string
+	Task     bool
+	Done     bool
+	Children *List
+
+	src *itemSource
+}
+
+// listSource and itemSource keep what a list looked like when parsed, so it can be exported unchanged.
+type listSource struct {
+	items  []*ListItem
+	lines  []string
+	indent string
+}
+
+type itemSource struct {
+	text     string
+	task     bool
+	done     bool
+	children *List
+	indent   string
+	marker   string
+	first    string   // the first line after the marker, spacing included
+	more     []string // the following text lines, as written
+	tail     []string // lines after the nested list, kept as written
+	lines    []string // every line of the item, without trailing blank lines
+	trailing int      // blank lines between the item and the next one
+}
+
+// NewParagraph creates a paragraph block from text.
+func NewParagraph(text string) Block {
+	return Block{Kind: Paragraph, Lines: strings.Split(text, "\n")}
+}
+
+// NewList creates a list block holding items.
+func NewList(numbered bool, items ...*ListItem) Block {
+	b := Block{Kind: BulletList, List: &List{Numbered: numbered, Items: items}}
+	if numbered {
+		b.Kind = NumberedList
+	}
+	return b
+}
+
+// Blocks parses the body of the section into blocks.
+func (s Section) Blocks() []Block {
+	return ParseBlocks(s.Body)
+}
+
+// SetBlocks replaces the body of the section with blocks.
+func (s *Section) SetBlocks(blocks []Block) {
+	s.Body = RenderBlocks(blocks)
+}
+
+// Insert adds item to the list so that it is at index i.
+func (l *List) Insert(i int, item *ListItem) {
+	if i < 0 || i > len(l.Items) {
+		i = len(l.Items)
+	}
+	items := append([]*ListItem{}, l.Items[:i]...)
+	items = append(items, item)
+	l.Items = append(items, l.Items[i:]...)
+}
+
+// Remove takes the item at index i out of the list and returns it.
+func (l *List) Remove(i int) *ListItem {
+	if i < 0 || i >= len(l.Items) {
+		return nil
+	}
+	item := l.Items[i]
+	l.Items = append(append([]*ListItem{}, l.Items[:i]...), l.Items[i+1:]...)
+	return item
+}
+
+// Move moves the item at index from so that it ends up at index to.
+func (l *List) Move(from, to int) {
+	if item := l.Remove(from); item != nil {
+		l.Insert(to, item)
+	}
+}
+
+// ParseBlocks splits a section body into blocks.
+func ParseBlocks(body string) []Block {
+	if body == "" {
+		return nil
+	}
+
+	lines := strings.Split(body, "\n")
+	var blocks []Block
+	blank := 0
+	for i := 0; i < len(lines); {
+		l := lines[i]
+		if isBlank(l) {
+			blank++
+			i++
+			continue
+		}
+
+		b := Block{blank: blank, parsed: true}
+		start := i
+		switch {
+		case fenceRegex.MatchString(l):
+			b.Kind = CodeFence
+			fence := strings.TrimSpace(l)
+			fence = fence[:len(fence)-len(strings.TrimLeft(fence, fence[:1]))]
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					i++
+					break
+				}
+			}
+		case itemRegex.MatchString(l):
+			b.List, i = parseList(lines, i)
+			b.Kind = BulletList
+			if b.List.Numbered {
+				b.Kind = NumberedList
+			}
+		case isQuote(l):
+			b.Kind = Quote
+			for i++; i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i], true); i++ {
+			}
+		case isTable(lines, i):
+			b.Kind = Table
+			for i++; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+			}
+		default:
+			b.Kind = Paragraph
+			for i++; i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i], false) && !isTable(lines, i); i++ {
+			}
+		}
+		b.Lines = lines[start:i]
+		blocks = append(blocks, b)
+		blank = 0
+	}
+	return blocks
+}
+
+// RenderBlocks joins blocks back into a section body.
+// Blocks that were parsed and left unchanged are written exactly as they were.
+func RenderBlocks(blocks []Block) string {
+	var out []string
+	for i, b := range blocks {
+		if i != 0 {
+			blank := 1
+			if b.parsed {
+				blank = b.blank
+			}
+			for j := 0; j < blank; j++ {
+				out = append(out, "")
+			}
+		}
+		if b.List != nil {
+			out = append(out, b.List.render(listIndent(b.List))...)
+		} else {
+			out = append(out, b.Lines...)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func parseList(lines []string, i int) (*List, int) {
+	m := itemRegex.FindStringSubmatch(lines[i])
+	indent := m[1]
+	l := &List{Numbered: isNumbered(m[2])}
+	start := i
+	for i < len(lines) {
+		m := itemRegex.FindStringSubmatch(lines[i])
+		if m == nil || m[1] != indent || isNumbered(m[2]) != l.Numbered {
+			break
+		}
+		var item *ListItem
+		item, i = parseItem(lines, i, m)
+		l.Items = append(l.Items, item)
+	}
+	l.src = &listSource{items: append([]*ListItem{}, l.Items...), lines: lines[start:i], indent: indent}
+	return l, i
+}
+
+func parseItem(lines []string, i int, m []string) (*ListItem, int) {
+	indent, marker, space, rest := m[1], m[2], m[3], m[4]
+	content := len(indent) + len(marker) + len(space)
+	if space == "" {
+		content++
+	}
+
+	item := &ListItem{}
+	src := &itemSource{indent: indent, marker: marker, first: space + rest}
+	text := []string{rest}
+	start := i
+	end := i + 1
+	for i++; i < len(lines); i++ {
+		l := lines[i]
+		if isBlank(l) {
+			j := i
+			for j < len(lines) && isBlank(lines[j]) {
+				j++
+			}
+			if j == len(lines) {
+				break
+			}
+			next := itemRegex.FindStringSubmatch(lines[j])
+			if next != nil && next[1] == indent && isNumbered(next[2]) == isNumbered(marker) {
+				// A blank line between two items of the same list.
+				src.trailing = j - i
+				i = j
+				break
+			}
+			if indentWidth(lines[j]) <= len(indent) {
+				break
+			}
+			continue
+		}
+
+		if next := itemRegex.FindStringSubmatch(l); next != nil {
+			if len(next[1]) <= len(indent) {
+				break
+			}
+			if item.Children == nil && len(src.tail) == 0 {
+				item.Children, i = parseList(lines, i)
+				end = i
+				i--
+				continue
+			}
+		} else if indentWidth(l) <= len(indent) && startsBlock(l, false) {
+			break
+		}
+
+		// Blank lines are only part of the item if more of it follows.
+		for j := end; j < i; j++ {
+			if item.Children == nil {
+				text = append(text, "")
+				src.more = append(src.more, lines[j])
+			} else {
+				src.tail = append(src.tail, lines[j])
+			}
+		}
+		if item.Children == nil {
+			cut := content
+			if cut > len(l) {
+				cut = len(l)
+			}
+			text = append(text, strings.TrimLeft(l[:cut], " \t")+l[cut:])
+			src.more = append(src.more, l)
+		} else {
+			src.tail = append(src.tail, l)
+		}
+		end = i + 1
+	}
+	src.lines = lines[start:end]
+
+	item.Text = strings.Join(text, "\n")
+	switch {
+	case strings.HasPrefix(item.Text, "[ ] "):
+		item.Task = true
+		item.Text = item.Text[4:]
+	case strings.HasPrefix(item.Text, "[x] "), strings.HasPrefix(item.Text, "[X] "):
+		item.Task, item.Done = true, true
+		item.Text = item.Text[4:]
+	}
+	src.text, src.task, src.done, src.children = item.Text, item.Task, item.Done, item.Children
+	item.src = src
+	if src.trailing == 0 {
+		return item, end
+	}
+	return item, i
+}
+
+func (l *List) unchanged() bool {
+	if l.src == nil || len(l.Items) != len(l.src.items) {
+		return false
+	}
+	for i, item := range l.Items {
+		if item != l.src.items[i] || !item.unchanged() {
+			return false
+		}
+	}
+	return true
+}
+
+func (it *ListItem) unchanged() bool {
+	return it.textUnchanged() && it.Children == it.src.children &&
+		(it.Children == nil || it.Children.unchanged())
+}
+
+func (it *ListItem) textUnchanged() bool {
+	return it.src != nil && it.Text == it.src.text && it.Task == it.src.task && it.Done == it.src.done
+}
+
+func listIndent(l *List) string {
+	if l.src != nil {
+		return l.src.indent
+	}
+	return ""
+}
+
+func (l *List) render(indent string) []string {
+	if l.unchanged() && l.src.indent == indent {
+		return l.src.lines
+	}
+
+	loose := false
+	bullet, delim, number := "-", ".", 1
+	if l.src != nil && len(l.src.items) > 0 {
+		first := l.src.items[0].src
+		bullet = first.marker
+		if l.Numbered {
+			delim = first.marker[len(first.marker)-1:]
+			number, _ = strconv.Atoi(first.marker[:len(first.marker)-1])
+		}
+		for _, it := range l.src.items[:len(l.src.items)-1] {
+			loose = loose || it.src.trailing > 0
+		}
+	}
+
+	var out []string
+	for i, it := range l.Items {
+		marker := bullet
+		if l.Numbered {
+			marker = strconv.Itoa(number+i) + delim
+		} else if it.src != nil {
+			marker = it.src.marker
+		}
+		out = append(out, it.render(indent, marker)...)
+
+		if i == len(l.Items)-1 {
+			break
+		}
+		blank := 0
+		if it.src != nil {
+			blank = it.src.trailing
+		} else if loose {
+			blank = 1
+		}
+		for j := 0; j < blank; j++ {
+			out = append(out, "")
+		}
+	}
+	return out
+}
+
+func (it *ListItem) render(indent, marker string) []string {
+	if it.unchanged() && it.src.indent == indent && it.src.marker == marker {
+		return it.src.lines
+	}
+
+	content := indent + strings.Repeat(" ", len(marker)+1)
+	var out []string
+	if it.textUnchanged() {
+		out = append(out, indent+marker+it.src.first)
+		out = append(out, it.src.more...)
+	} else {
+		box := ""
+		if it.Task && it.Done {
+			box = "[x] "
+		} else if it.Task {
+			box = "[ ] "
+		}
+		for i, l := range strings.Split(it.Text, "\n") {
+			switch {
+			case i == 0:
+				out = append(out, strings.TrimRight(indent+marker+" "+box+l, " "))
+			case l == "":
+				out = append(out, "")
+			default:
+				out = append(out, content+l)
+			}
+		}
+	}
+
+	if it.Children != nil {
+		childIndent := content
+		if it.Children.src != nil {
+			childIndent = it.Children.src.indent
+		}
+		out = append(out, it.Children.render(childIndent)...)
+	}
+	if it.src != nil {
+		out = append(out, it.src.tail...)
+	}
+	return out
+}
+
+// startsBlock reports if line starts a new block, ending the paragraph or quote before it.
+func startsBlock(line string, inQuote bool) bool {
+	if fenceRegex.MatchString(line) || itemRegex.MatchString(line) && indentWidth(line) < 4 {
+		return true
+	}
+	return !inQuote && isQuote(line)
+}
+
+func isTable(lines []string, i int) bool {
+	return strings.HasPrefix(strings.TrimSpace(lines[i]), "|") &&
+		i+1 < len(lines) && tableDelimiter.MatchString(lines[i+1])
+}
+
+func isQuote(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " "), ">") && indentWidth(line) < 4
+}
+
+func isNumbered(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentWidth(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
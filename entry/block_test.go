@@ -0,0 +1,100 @@
+package entry
+
+import (
+	"reflect"
+	"testing"
+)
+
+const blockBody = "Some text\nacross lines.\n\n" +
+	"- [x] done\n  - nested\n    more nested\n- [ ] open\n\n" +
+	"1. first\n2. second\n\n" +
+	"```go\nfunc main() {\n\n}\n```\n\n" +
+	"> quoted\n> text\n\n\n" +
+	"| a | b |\n|---|---|\n| 1 | 2 |\n" +
+	"* loose\n\n* list"
+
+func TestParseBlocks(t *testing.T) {
+	blocks := ParseBlocks(blockBody)
+
+	var kinds []BlockKind
+	for _, b := range blocks {
+		kinds = append(kinds, b.Kind)
+	}
+	expected := []BlockKind{Paragraph, BulletList, NumberedList, CodeFence, Quote, Table, BulletList}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf(testFail, kinds, expected, "kinds")
+	}
+
+	items := blocks[1].List.Items
+	if len(items) != 2 {
+		t.Fatalf(testFail, len(items), 2, "items")
+	}
+	if items[0].Text != "done" || !items[0].Task || !items[0].Done {
+		t.Errorf(testFail, *items[0], "done task", "done item")
+	}
+	if items[1].Text != "open" || !items[1].Task || items[1].Done {
+		t.Errorf(testFail, *items[1], "open task", "open item")
+	}
+	nested := items[0].Children.Items
+	if len(nested) != 1 || nested[0].Text != "nested\nmore nested" {
+		t.Errorf(testFail, nested, "nested\nmore nested", "nested item")
+	}
+	if len(blocks[6].List.Items) != 2 {
+		t.Errorf(testFail, len(blocks[6].List.Items), 2, "loose items")
+	}
+}
+
+func TestRenderBlocks(t *testing.T) {
+	tests := map[string]struct {
+		Body   string
+		Change func(blocks []Block) []Block
+		Out    string
+	}{
+		"unchanged": {
+			Body:   blockBody,
+			Change: func(b []Block) []Block { return b },
+			Out:    blockBody},
+		"add item": {
+			Body: "- a\n- b",
+			Change: func(b []Block) []Block {
+				b[0].List.Insert(1, &ListItem{Text: "new", Task: true})
+				return b
+			},
+			Out: "- a\n- [ ] new\n- b"},
+		"remove item": {
+			Body: "1. a\n2. b\n   more b\n3. c",
+			Change: func(b []Block) []Block {
+				b[0].List.Remove(0)
+				return b
+			},
+			Out: "1. b\n   more b\n2. c"},
+		"move item": {
+			Body: "* a\n\n* b\n  - b child\n\n* c",
+			Change: func(b []Block) []Block {
+				b[0].List.Move(2, 0)
+				return b
+			},
+			Out: "* c\n* a\n\n* b\n  - b child"},
+		"change nested item": {
+			Body: "Intro\n\n- a\n  - [ ] child\n- b\n\nOutro",
+			Change: func(b []Block) []Block {
+				b[1].List.Items[0].Children.Items[0].Done = true
+				return b
+			},
+			Out: "Intro\n\n- a\n  - [x] child\n- b\n\nOutro"},
+		"add blocks": {
+			Body: "text",
+			Change: func(b []Block) []Block {
+				return append(b, NewList(true, &ListItem{Text: "one"}, &ListItem{Text: "two"}))
+			},
+			Out: "text\n\n1. one\n2. two"},
+	}
+
+	for id, test := range tests {
+		s := Section{Title: "Do", Body: test.Body}
+		s.SetBlocks(test.Change(s.Blocks()))
+		if s.Body != test.Out {
+			t.Errorf(testFail, s.Body, test.Out, id)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package entry
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	dateRegex  = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	weekRegex  = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+	monthRegex = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+)
+
+// Date is the calendar day an entry was written on, and the name of that entry.
+// The zero Date is not a valid date, and is used for "no date".
+type Date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// ParseDate parses a date written as YYYY-MM-DD, rejecting days that do not exist.
+func ParseDate(s string) (Date, error) {
+	m := dateRegex.FindStringSubmatch(s)
+	if m == nil {
+		return Date{}, fmt.Errorf("invalid date %q, dates must look like 2019-01-31", s)
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	if month < 1 || month > 12 {
+		return Date{}, fmt.Errorf("invalid date %q, there is no month %d", s, month)
+	}
+	if last := daysIn(year, time.Month(month)); day < 1 || day > last {
+		return Date{}, fmt.Errorf("invalid date %q, %s %d has %d days", s, time.Month(month), year, last)
+	}
+	return Date{year: year, month: time.Month(month), day: day}, nil
+}
+
+// DateOf returns the day of t, in t's location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{year: y, month: m, day: d}
+}
+
+// Today returns the local date, with days starting at midnight. Clock.Today can start them later.
+func Today() Date {
+	return DateOf(time.Now())
+}
+
+// Clock tells the date for someone whose days start at DayStartsAt in Location,
+// so that working past midnight still counts as the day the work started on.
+// The zero Clock uses the local time zone, and days starting at midnight.
+type Clock struct {
+	Location *time.Location
+	// DayStartsAt is how long after midnight a new day starts, less than a day.
+	DayStartsAt time.Duration
+}
+
+// ParseClock makes a Clock from the name of a time zone, such as "Europe/Berlin", and the time of day
+// days start at, written as HH:MM. Empty strings stand for the local time zone and for midnight.
+func ParseClock(timezone, dayStartsAt string) (Clock, error) {
+	var c Clock
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return Clock{}, fmt.Errorf("unknown timezone %q", timezone)
+		}
+		c.Location = loc
+	}
+	if dayStartsAt != "" {
+		t, err := time.Parse("15:04", dayStartsAt)
+		if err != nil {
+			return Clock{}, fmt.Errorf("invalid time %q, days must start at a time like 04:00", dayStartsAt)
+		}
+		c.DayStartsAt = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return c, nil
+}
+
+// DateOf returns the date t counts as for c.
+func (c Clock) DateOf(t time.Time) Date {
+	loc := c.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	// Going back by DayStartsAt across a change of the clocks would be off by the change,
+	// so the time of day is compared instead.
+	t = t.In(loc)
+	since := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	d := DateOf(t)
+	if since < c.DayStartsAt {
+		d = d.AddDays(-1)
+	}
+	return d
+}
+
+// Today returns today's date for c.
+func (c Clock) Today() Date {
+	return c.DateOf(time.Now())
+}
+
+// Zone names c's time zone, or gives its current offset from UTC, such as "+02:00", if it is the local one.
+func (c Clock) Zone() string {
+	if c.Location == nil || c.Location == time.Local {
+		return time.Now().Format("-07:00")
+	}
+	return c.Location.String()
+}
+
+// ParseWeek returns the Monday and Sunday of an ISO week written as YYYY-Www.
+func ParseWeek(week string) (Date, Date, error) {
+	m := weekRegex.FindStringSubmatch(week)
+	if m == nil {
+		return Date{}, Date{}, fmt.Errorf("invalid week %q, weeks must look like 2026-W42", week)
+	}
+	year, _ := strconv.Atoi(m[1])
+	num, _ := strconv.Atoi(m[2])
+
+	// January 4th is always in the first week of the year.
+	monday := Date{year: year, month: time.January, day: 4}.StartOfWeek().AddDays((num - 1) * 7)
+	if y, w := monday.ISOWeek(); y != year || w != num {
+		return Date{}, Date{}, fmt.Errorf("%d has no week %d", year, num)
+	}
+	return monday, monday.AddDays(6), nil
+}
+
+// ParseMonth returns the first and last days of a month written as YYYY-MM.
+func ParseMonth(month string) (Date, Date, error) {
+	m := monthRegex.FindStringSubmatch(month)
+	num := 0
+	if m != nil {
+		num, _ = strconv.Atoi(m[2])
+	}
+	if num < 1 || num > 12 {
+		return Date{}, Date{}, fmt.Errorf("invalid month %q, months must look like 2026-10", month)
+	}
+	year, _ := strconv.Atoi(m[1])
+	first := Date{year: year, month: time.Month(num), day: 1}
+	return first, first.EndOfMonth(), nil
+}
+
+// String formats d as YYYY-MM-DD, or returns "" for the zero Date.
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.year, d.month, d.day)
+}
+
+// Name returns the name of the entry written on d.
+func (d Date) Name() EntryName {
+	return EntryName(d.String())
+}
+
+// Date parses the entry name as a date.
+func (n EntryName) Date() (Date, error) {
+	return ParseDate(string(n))
+}
+
+// Time returns the local midnight starting d.
+func (d Date) Time() time.Time {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, time.Local)
+}
+
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+func (d Date) Year() int             { return d.year }
+func (d Date) Month() time.Month     { return d.month }
+func (d Date) Day() int              { return d.day }
+func (d Date) Weekday() time.Weekday { return d.utc().Weekday() }
+
+// IsWeekend reports whether d is a Saturday or a Sunday.
+func (d Date) IsWeekend() bool {
+	return d.Weekday() == time.Saturday || d.Weekday() == time.Sunday
+}
+
+// Compare returns -1, 0 or 1 if d is before, the same day as, or after d2.
+func (d Date) Compare(d2 Date) int {
+	switch {
+	case d.Before(d2):
+		return -1
+	case d2.Before(d):
+		return 1
+	}
+	return 0
+}
+
+func (d Date) Before(d2 Date) bool {
+	if d.year != d2.year {
+		return d.year < d2.year
+	}
+	if d.month != d2.month {
+		return d.month < d2.month
+	}
+	return d.day < d2.day
+}
+
+func (d Date) After(d2 Date) bool {
+	return d2.Before(d)
+}
+
+// AddDays returns the date n days after d, or before it if n is negative.
+func (d Date) AddDays(n int) Date {
+	return DateOf(d.utc().AddDate(0, 0, n))
+}
+
+// DaysSince returns the number of days from d2 to d.
+func (d Date) DaysSince(d2 Date) int {
+	return int(d.utc().Sub(d2.utc()).Hours() / 24)
+}
+
+// ISOWeek returns the ISO year and week d is in.
+func (d Date) ISOWeek() (int, int) {
+	return d.utc().ISOWeek()
+}
+
+// Week formats the ISO week d is in as YYYY-Www.
+func (d Date) Week() string {
+	y, w := d.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", y, w)
+}
+
+// MonthString formats the month d is in as YYYY-MM.
+func (d Date) MonthString() string {
+	return fmt.Sprintf("%04d-%02d", d.year, d.month)
+}
+
+// StartOfWeek returns the Monday of the week d is in.
+func (d Date) StartOfWeek() Date {
+	return d.AddDays(-((int(d.Weekday()) + 6) % 7))
+}
+
+// StartOfMonth returns the first day of the month d is in.
+func (d Date) StartOfMonth() Date {
+	return Date{year: d.year, month: d.month, day: 1}
+}
+
+// EndOfMonth returns the last day of the month d is in.
+func (d Date) EndOfMonth() Date {
+	return Date{year: d.year, month: d.month, day: daysIn(d.year, d.month)}
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Date) UnmarshalText(text []byte) error {
+	parsed, err := ParseDate(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// utc is midnight UTC of d, which unlike local time always has days 24 hours long.
+func (d Date) utc() time.Time {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, time.UTC)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
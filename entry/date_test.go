@@ -0,0 +1,164 @@
+package entry
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := map[string]struct {
+		Out string
+		Err string
+	}{
+		"2019-01-01":        {Out: "2019-01-01"},
+		"2020-02-29":        {Out: "2020-02-29"},
+		"2019-02-29":        {Err: `invalid date "2019-02-29", February 2019 has 28 days`},
+		"2019-13-45":        {Err: `invalid date "2019-13-45", there is no month 13`},
+		"2019-01-00":        {Err: `invalid date "2019-01-00", January 2019 has 31 days`},
+		"2019-01-021":       {Err: `invalid date "2019-01-021", dates must look like 2019-01-31`},
+		"backup-2019-01-01": {Err: `invalid date "backup-2019-01-01", dates must look like 2019-01-31`},
+		"":                  {Err: `invalid date "", dates must look like 2019-01-31`},
+	}
+
+	for in, test := range tests {
+		d, err := ParseDate(in)
+		if err != nil {
+			if err.Error() != test.Err {
+				t.Errorf(testFail, err, test.Err, in)
+			}
+			continue
+		}
+		if d.String() != test.Out || test.Err != "" {
+			t.Errorf(testFail, d, test.Out, in)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	d, _ := ParseDate("2026-10-18")
+	later, _ := ParseDate("2026-11-02")
+
+	tests := map[string]struct {
+		Out      interface{}
+		Expected interface{}
+	}{
+		"weekday":       {Out: d.Weekday(), Expected: time.Sunday},
+		"weekend":       {Out: d.IsWeekend(), Expected: true},
+		"week":          {Out: d.Week(), Expected: "2026-W42"},
+		"month":         {Out: d.MonthString(), Expected: "2026-10"},
+		"start of week": {Out: d.StartOfWeek().String(), Expected: "2026-10-12"},
+		"end of month":  {Out: d.EndOfMonth().String(), Expected: "2026-10-31"},
+		"add days":      {Out: d.AddDays(15), Expected: later},
+		"days since":    {Out: later.DaysSince(d), Expected: 15},
+		"before":        {Out: d.Before(later) && !later.Before(d) && !d.Before(d), Expected: true},
+		"compare":       {Out: []int{d.Compare(later), later.Compare(d), d.Compare(d)}, Expected: []int{-1, 1, 0}},
+		"of time":       {Out: DateOf(time.Date(2026, 10, 18, 23, 59, 0, 0, time.Local)), Expected: d},
+		"zero":          {Out: Date{}.String() + string(Date{}.Name()), Expected: ""},
+	}
+
+	for id, test := range tests {
+		if !reflect.DeepEqual(test.Out, test.Expected) {
+			t.Errorf(testFail, test.Out, test.Expected, id)
+		}
+	}
+
+	bts, err := json.Marshal(map[Date]int{d: 1})
+	if err != nil || string(bts) != `{"2026-10-18":1}` {
+		t.Errorf(testFail, string(bts), `{"2026-10-18":1}`, "json")
+	}
+	var decoded map[Date]int
+	if err := json.Unmarshal([]byte(`{"2026-13-01":1}`), &decoded); err == nil {
+		t.Errorf("decoded an invalid date")
+	}
+}
+
+func TestParseWeek(t *testing.T) {
+	tests := map[string]struct {
+		From, To string
+		Err      string
+	}{
+		"2026-W42": {From: "2026-10-12", To: "2026-10-18"},
+		"2026-W01": {From: "2025-12-29", To: "2026-01-04"},
+		"2020-W53": {From: "2020-12-28", To: "2021-01-03"},
+		"2025-W53": {Err: "2025 has no week 53"},
+		"2026-42":  {Err: `invalid week "2026-42", weeks must look like 2026-W42`},
+	}
+
+	for week, test := range tests {
+		from, to, err := ParseWeek(week)
+		if err != nil {
+			if err.Error() != test.Err {
+				t.Errorf(testFail, err, test.Err, week)
+			}
+			continue
+		}
+		if d := from.String() + " " + to.String(); d != test.From+" "+test.To {
+			t.Errorf(testFail, d, test.From+" "+test.To, week)
+		}
+	}
+}
+
+func TestParseMonth(t *testing.T) {
+	tests := map[string]struct {
+		From, To string
+		Err      string
+	}{
+		"2026-10": {From: "2026-10-01", To: "2026-10-31"},
+		"2024-02": {From: "2024-02-01", To: "2024-02-29"},
+		"2026-13": {Err: `invalid month "2026-13", months must look like 2026-10`},
+		"2026-1":  {Err: `invalid month "2026-1", months must look like 2026-10`},
+	}
+
+	for month, test := range tests {
+		from, to, err := ParseMonth(month)
+		if err != nil {
+			if err.Error() != test.Err {
+				t.Errorf(testFail, err, test.Err, month)
+			}
+			continue
+		}
+		if d := from.String() + " " + to.String(); d != test.From+" "+test.To {
+			t.Errorf(testFail, d, test.From+" "+test.To, month)
+		}
+	}
+}
+
+func TestClock_DateOf(t *testing.T) {
+	berlin, err := ParseClock("Europe/Berlin", "04:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		Clock Clock
+		Time  string
+		Out   string
+	}{
+		"midnight":                        {Clock: Clock{Location: time.UTC}, Time: "2019-01-31T23:59:59Z", Out: "2019-01-31"},
+		"after midnight":                  {Clock: Clock{Location: time.UTC}, Time: "2019-02-01T00:00:00Z", Out: "2019-02-01"},
+		"before day starts":               {Clock: berlin, Time: "2019-02-01T02:59:59Z", Out: "2019-01-31"},
+		"when day starts":                 {Clock: berlin, Time: "2019-02-01T03:00:00Z", Out: "2019-02-01"},
+		"other time zone":                 {Clock: berlin, Time: "2019-01-31T22:30:00-05:00", Out: "2019-02-01"},
+		"clocks went forward":             {Clock: berlin, Time: "2019-03-31T01:59:00Z", Out: "2019-03-30"},
+		"clocks went forward, day starts": {Clock: berlin, Time: "2019-03-31T02:00:00Z", Out: "2019-03-31"},
+		"clocks go back":                  {Clock: berlin, Time: "2019-10-27T02:59:00Z", Out: "2019-10-26"},
+		"day starts at new year":          {Clock: berlin, Time: "2019-01-01T02:00:00Z", Out: "2018-12-31"},
+	}
+
+	for id, test := range tests {
+		tm, err := time.Parse(time.RFC3339, test.Time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := test.Clock.DateOf(tm); d.String() != test.Out {
+			t.Errorf(testFail, d, test.Out, id)
+		}
+	}
+
+	for _, bad := range [][2]string{{"Mars/Olympus", ""}, {"", "4am"}, {"", "24:00"}} {
+		if _, err := ParseClock(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseClock(%q, %q) gave no error", bad[0], bad[1])
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package entry
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"reflect"
 	"strings"
+
+	"github.com/ifo/dev.journal/crypt"
 )
 
 type Style int
@@ -34,6 +40,14 @@ type Section struct {
 	Body  string `json:"body"`
 }
 
+// Sealed reports whether the body of the section is encrypted.
+func (s Section) Sealed() bool {
+	return crypt.IsSealed(s.Body)
+}
+
+// ErrSealed is returned when importing an entry encrypted as a whole, which has nothing to read without its key.
+var ErrSealed = errors.New("entry is encrypted")
+
 // Import Style as "underline" or "pound".
 func (s *Style) UnmarshalJSON(buf []byte) error {
 	str := ""
@@ -72,6 +86,9 @@ var DefaultUnderline = Entry{
 }
 
 func Import(str string) (Entry, error) {
+	if crypt.IsSealed(str) {
+		return Entry{}, ErrSealed
+	}
 	if len(str) < 3 {
 		return Entry{}, fmt.Errorf("entry is empty")
 	}
@@ -84,7 +101,12 @@ func Import(str string) (Entry, error) {
 	return Entry{}, fmt.Errorf("entries must start with a title")
 }
 
+// ImportPublic is Import keeping only the sections in pubSections.
+// Their bodies are all it reads, so the encrypted bodies of private sections do not need a key.
 func ImportPublic(str string, pubSections map[string]struct{}) (Entry, error) {
+	if crypt.IsSealed(str) {
+		return Entry{}, ErrSealed
+	}
 	if len(str) < 3 {
 		return Entry{}, fmt.Errorf("entry is empty")
 	}
@@ -188,15 +210,32 @@ func (e Entry) Export() string {
 	return out
 }
 
-func (e *Entry) ImportFiles(
-	pubSections map[string]struct{},
-	basePath string,
-	readFile func(string) ([]byte, error)) error {
+// Public returns a copy of the entry holding only the sections whose lowercased titles are in pubSections.
+func (e Entry) Public(pubSections map[string]struct{}) Entry {
+	sections := []Section(nil)
+	for _, s := range e.Sections {
+		if _, ok := pubSections[strings.ToLower(s.Title)]; ok {
+			sections = append(sections, s)
+		}
+	}
+	e.Sections = sections
+	return e
+}
 
+// ImportFiles reads the files linked from the public sections of e, from the folder dir of fsys.
+// Files filter does not allow are left out.
+func (e *Entry) ImportFiles(pubSections map[string]struct{}, fsys fs.FS, dir string, filter FileFilter) error {
 	publicFiles := e.publicFileList(pubSections)
 	fileMap := map[string][]byte{}
 	for _, f := range publicFiles {
-		data, err := readFile(filepath.Join(basePath, f))
+		info, err := fs.Stat(fsys, path.Join(dir, f))
+		if err != nil {
+			return err
+		}
+		if filter.Skips(f, info.Size()) != "" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, f))
 		if err != nil {
 			return err
 		}
@@ -227,6 +266,18 @@ func (e Entry) Equals(e2 Entry) bool {
 	return reflect.DeepEqual(e, e2)
 }
 
+// Hash is a hex encoded SHA-256 hash of the entry's contents, without regard to its name
+// or to the FileNames found next to it. Entries that are Equal have the same Hash.
+func (e Entry) Hash() string {
+	bts, _ := json.Marshal(struct {
+		Style    Style
+		Sections []Section
+		Files    map[string][]byte
+	}{e.Style, e.Sections, e.PublicFiles})
+	sum := sha256.Sum256(bts)
+	return hex.EncodeToString(sum[:])
+}
+
 // Two lines are a title if there is at least 1 non space rune on the first line
 // and the 2nd line is more than 1 "=" sign, and entirely "=" signs.
 func areTitle(line1, line2 string) bool {
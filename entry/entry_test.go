@@ -66,6 +66,16 @@ func TestImport(t *testing.T) {
 			In:  "multi\n=\n\nmultiple\nlines",
 			E:   Entry{Style: Underline, Sections: []Section{{Title: "multi", Body: "multiple\nlines"}}},
 			Err: nil},
+		// Encrypted entries.
+		"sealed entry": {
+			In:  "-----BEGIN DEVJ ENCRYPTED-----\nc2VhbGVk\n-----END DEVJ ENCRYPTED-----\n",
+			E:   Entry{},
+			Err: ErrSealed},
+		"sealed section": {
+			In: "# Do\n\n-----BEGIN DEVJ ENCRYPTED-----\nc2VhbGVk\n-----END DEVJ ENCRYPTED-----\n",
+			E: Entry{Sections: []Section{
+				{Title: "Do", Body: "-----BEGIN DEVJ ENCRYPTED-----\nc2VhbGVk\n-----END DEVJ ENCRYPTED-----"}}},
+			Err: nil},
 	}
 
 	for id, test := range tests {
@@ -1,34 +1,303 @@
 package entry
 
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
 // Journal is a map of Entries where they key is the string of the date the Entry was written.
+// Entries holds the latest revision of each entry, and Revisions every revision, oldest first.
 type Journal struct {
-	Entries map[EntryName]Entry `json:"entries"`
+	Entries   map[EntryName]Entry      `json:"entries"`
+	Revisions map[EntryName][]Revision `json:"revisions,omitempty"`
+	// Timezone is the time zone the journal's dates are in, such as "Europe/Berlin",
+	// or its offset from UTC, such as "+02:00", if it has no name.
+	Timezone string `json:"timezone,omitempty"`
+
+	// hashes indexes revisions by their Hash. It is only changed by the methods that change the journal,
+	// so reading the journal never writes to it, and it may be out of date if Entries is changed directly.
+	// indexed is the number of entries when it was built, so Add can tell when to build it again.
+	hashes  map[string][]revisionRef
+	indexed int
+}
+
+// Revision is a single version of an entry.
+// Revisions of an entry are numbered from 1, in the order they were added.
+type Revision struct {
+	Number int       `json:"number"`
+	Time   time.Time `json:"time"`
+	Hash   string    `json:"hash"`
+	Entry  Entry     `json:"entry"`
+}
+
+type revisionRef struct {
+	name   EntryName
+	number int
 }
 
 // NewJournal creates a new empty journal with a non-nil Entries map.
 func NewJournal() *Journal {
-	return &Journal{Entries: map[EntryName]Entry{}}
+	return &Journal{Entries: map[EntryName]Entry{}, Revisions: map[EntryName][]Revision{}, hashes: map[string][]revisionRef{}}
 }
 
+// Add adds e as the latest revision of the entry called e.Name, unless it is equal to the latest revision already.
 func (j *Journal) Add(e Entry) {
-	if existing, exists := j.Entries[e.Name]; exists {
-		if !e.Equals(existing) {
-			// TODO: a better updated version naming scheme.
-			e.Name = e.Name + "1"
-			j.Add(e)
-		}
+	j.AddAt(e, time.Now())
+}
+
+// AddAt adds e like Add does, recording t as the time of the revision.
+func (j *Journal) AddAt(e Entry, t time.Time) {
+	revs := j.History(e.Name)
+	if len(revs) > 0 && e.Equals(revs[len(revs)-1].Entry) {
 		return
 	}
 
+	rev := Revision{Number: len(revs) + 1, Time: t, Hash: e.Hash(), Entry: e}
+	if j.Revisions == nil {
+		j.Revisions = map[EntryName][]Revision{}
+	}
+	if j.hashes == nil || j.indexed != len(j.Entries) {
+		j.reindex()
+	}
+	j.Revisions[e.Name] = append(revs, rev)
 	j.Entries[e.Name] = e
+	if len(revs) == 0 {
+		j.indexed++
+	}
+	j.hashes[rev.Hash] = append(j.hashes[rev.Hash], revisionRef{e.Name, rev.Number})
+}
+
+// reindex builds the index of revisions by Hash from every entry in the journal.
+func (j *Journal) reindex() {
+	j.hashes = map[string][]revisionRef{}
+	for name := range j.Entries {
+		for _, rev := range j.History(name) {
+			j.hashes[rev.Hash] = append(j.hashes[rev.Hash], revisionRef{name, rev.Number})
+		}
+	}
+	j.indexed = len(j.Entries)
+}
+
+// History lists every revision of the entry called name, oldest first.
+// An entry put into Entries directly has a single revision without a time.
+func (j *Journal) History(name EntryName) []Revision {
+	if revs := j.Revisions[name]; len(revs) > 0 {
+		return revs
+	}
+	if e, ok := j.Entries[name]; ok {
+		return []Revision{{Number: 1, Hash: e.Hash(), Entry: e}}
+	}
+	return nil
 }
 
-// Contains is an expensive way of determining if a journal already has a specific entry.
+// LatestRevision finds the latest revision of the entry called name.
+func (j *Journal) LatestRevision(name EntryName) (Revision, bool) {
+	revs := j.History(name)
+	if len(revs) == 0 {
+		return Revision{}, false
+	}
+	return revs[len(revs)-1], true
+}
+
+// Revision finds revision number n of the entry called name.
+func (j *Journal) Revision(name EntryName, n int) (Revision, bool) {
+	revs := j.History(name)
+	if n < 1 || n > len(revs) {
+		return Revision{}, false
+	}
+	return revs[n-1], true
+}
+
+// Contains determines if a journal already has a specific entry, as any revision and under any name.
 func (j *Journal) Contains(e Entry) bool {
-	for _, entry := range j.Entries {
-		if entry.Equals(e) {
-			return true
+	_, ok := j.ByHash(e.Hash())
+	return ok
+}
+
+// ByHash finds the entry revision whose Hash is hash.
+// Revisions are looked up in the index, and every entry and revision is looked through if the index
+// has none, since entries put into, replaced in or deleted from the map directly are not indexed.
+// It does not change j, so it is safe to call alongside the journal's other readers.
+func (j *Journal) ByHash(hash string) (Entry, bool) {
+	for _, ref := range j.hashes[hash] {
+		if _, ok := j.Entries[ref.name]; !ok {
+			continue
 		}
+		if rev, ok := j.Revision(ref.name, ref.number); ok && rev.Entry.Hash() == hash {
+			return rev.Entry, true
+		}
+	}
+
+	for name, e := range j.Entries {
+		if e.Hash() == hash {
+			return e, true
+		}
+		for _, rev := range j.History(name) {
+			if rev.Hash == hash && rev.Entry.Hash() == hash {
+				return rev.Entry, true
+			}
+		}
+	}
+	return Entry{}, false
+}
+
+// wireRevision is how a Revision is encoded in JSON.
+// The latest revision of each entry leaves out its Entry, since it is already in Entries.
+type wireRevision struct {
+	Number int       `json:"number"`
+	Time   time.Time `json:"time"`
+	Hash   string    `json:"hash"`
+	Entry  *Entry    `json:"entry,omitempty"`
+}
+
+type wireJournal struct {
+	Entries   map[EntryName]Entry          `json:"entries"`
+	Revisions map[EntryName][]wireRevision `json:"revisions,omitempty"`
+	Timezone  string                       `json:"timezone,omitempty"`
+}
+
+func (j Journal) MarshalJSON() ([]byte, error) {
+	wj := wireJournal{Entries: j.Entries, Timezone: j.Timezone}
+	for name, revs := range j.Revisions {
+		if wj.Revisions == nil {
+			wj.Revisions = map[EntryName][]wireRevision{}
+		}
+		for i, rev := range revs {
+			wr := wireRevision{Number: rev.Number, Time: rev.Time, Hash: rev.Hash}
+			if i != len(revs)-1 {
+				e := rev.Entry
+				wr.Entry = &e
+			}
+			wj.Revisions[name] = append(wj.Revisions[name], wr)
+		}
+	}
+	return json.Marshal(wj)
+}
+
+func (j *Journal) UnmarshalJSON(buf []byte) error {
+	var wj wireJournal
+	if err := json.Unmarshal(buf, &wj); err != nil {
+		return err
+	}
+
+	*j = *NewJournal()
+	j.Timezone = wj.Timezone
+	for name, e := range wj.Entries {
+		if _, err := name.Date(); err != nil {
+			return fmt.Errorf("bad entry name: %v", err)
+		}
+		j.Entries[name] = e
+	}
+	for name, wrevs := range wj.Revisions {
+		latest, ok := wj.Entries[name]
+		if !ok {
+			return fmt.Errorf("revisions of %s have no latest entry", name)
+		}
+		var revs []Revision
+		for i, wr := range wrevs {
+			rev := Revision{Number: wr.Number, Time: wr.Time, Hash: wr.Hash, Entry: latest}
+			if wr.Entry != nil {
+				rev.Entry = *wr.Entry
+			} else if i != len(wrevs)-1 {
+				return fmt.Errorf("revision %d of %s has no entry", wr.Number, name)
+			}
+			revs = append(revs, rev)
+		}
+		j.Revisions[name] = revs
+	}
+	j.reindex()
+	return nil
+}
+
+// Names lists the names of the entries in the journal, oldest first.
+func (j *Journal) Names() []EntryName {
+	names := make([]EntryName, 0, len(j.Entries))
+	for name := range j.Entries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool { return names[a] < names[b] })
+	return names
+}
+
+// Ordered lists the entries in the journal, oldest first.
+// Each entry's Name is set to its key in Entries.
+func (j *Journal) Ordered() []Entry {
+	var out []Entry
+	for _, name := range j.Names() {
+		e := j.Entries[name]
+		e.Name = name
+		out = append(out, e)
+	}
+	return out
+}
+
+// Between returns a journal holding the entries written from through to, inclusive.
+// A zero from or to leaves that end of the range open. Entries not named after a date are left out.
+func (j *Journal) Between(from, to Date) *Journal {
+	return j.filter(func(name EntryName, e Entry) bool {
+		d, err := name.Date()
+		return err == nil && (from.IsZero() || !d.Before(from)) && (to.IsZero() || !d.After(to))
+	})
+}
+
+// WithSection returns a journal holding the entries that have a section titled title, ignoring case.
+func (j *Journal) WithSection(title string) *Journal {
+	return j.filter(func(name EntryName, e Entry) bool {
+		for _, s := range e.Sections {
+			if strings.EqualFold(s.Title, title) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithTag returns a journal holding the entries that use #tag, ignoring case.
+func (j *Journal) WithTag(tag string) *Journal {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	return j.filter(func(name EntryName, e Entry) bool {
+		for _, t := range e.Tags() {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Latest returns a journal holding the n newest entries.
+func (j *Journal) Latest(n int) *Journal {
+	names := j.Names()
+	if n < 0 {
+		n = 0
+	}
+	if n < len(names) {
+		names = names[len(names)-n:]
+	}
+	out := NewJournal()
+	for _, name := range names {
+		out.copyEntry(j, name)
+	}
+	return out
+}
+
+func (j *Journal) filter(keep func(EntryName, Entry) bool) *Journal {
+	out := NewJournal()
+	for name, e := range j.Entries {
+		if keep(name, e) {
+			out.copyEntry(j, name)
+		}
+	}
+	return out
+}
+
+// copyEntry copies the entry called name, and its revisions, from j2.
+func (j *Journal) copyEntry(j2 *Journal, name EntryName) {
+	j.Entries[name] = j2.Entries[name]
+	if revs, ok := j2.Revisions[name]; ok {
+		j.Revisions[name] = revs
 	}
-	return false
 }
@@ -1,36 +1,42 @@
 package entry
 
 import (
+	"encoding/json"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestJournal_Add(t *testing.T) {
 	entry1 := Entry{Name: "2019-01-01"}
 	entry2 := Entry{Name: "2019-01-02"}
 	entry2ul := Entry{Name: "2019-01-02", Style: Underline}
-	entry2ulAdded := Entry{Name: "2019-01-021", Style: Underline}
 	entry2diff := Entry{Name: "2019-01-02", Sections: []Section{{Title: "a"}}}
-	entry2diffAdded := Entry{Name: "2019-01-0211", Sections: []Section{{Title: "a"}}}
-	outJournal1 := NewJournal()
-	outJournal1.Entries[entry1.Name] = entry1
-	outJournal2 := NewJournal()
-	outJournal2.Entries[entry1.Name] = entry1
-	outJournal2.Entries[entry2.Name] = entry2
-	outJournal3 := NewJournal()
-	outJournal3.Entries[entry2.Name] = entry2
-	outJournal3.Entries[entry2ulAdded.Name] = entry2ulAdded
-	outJournal3.Entries[entry2diffAdded.Name] = entry2diffAdded
 
 	tests := map[string]struct {
-		ToAdd      []Entry
-		OutJournal *Journal
+		ToAdd     []Entry
+		Entries   map[EntryName]Entry
+		Revisions map[EntryName][]Entry
 	}{
-		"1": {ToAdd: []Entry{entry1}, OutJournal: outJournal1},
-		"2": {ToAdd: []Entry{entry1, entry1}, OutJournal: outJournal1},
-		"3": {ToAdd: []Entry{entry1, entry2}, OutJournal: outJournal2},
-		"4": {ToAdd: []Entry{entry1, entry2, entry1}, OutJournal: outJournal2},
-		"5": {ToAdd: []Entry{entry2, entry2ul, entry2diff}, OutJournal: outJournal3},
+		"1": {ToAdd: []Entry{entry1},
+			Entries:   map[EntryName]Entry{entry1.Name: entry1},
+			Revisions: map[EntryName][]Entry{entry1.Name: {entry1}}},
+		"2": {ToAdd: []Entry{entry1, entry1},
+			Entries:   map[EntryName]Entry{entry1.Name: entry1},
+			Revisions: map[EntryName][]Entry{entry1.Name: {entry1}}},
+		"3": {ToAdd: []Entry{entry1, entry2},
+			Entries:   map[EntryName]Entry{entry1.Name: entry1, entry2.Name: entry2},
+			Revisions: map[EntryName][]Entry{entry1.Name: {entry1}, entry2.Name: {entry2}}},
+		"4": {ToAdd: []Entry{entry1, entry2, entry1},
+			Entries:   map[EntryName]Entry{entry1.Name: entry1, entry2.Name: entry2},
+			Revisions: map[EntryName][]Entry{entry1.Name: {entry1}, entry2.Name: {entry2}}},
+		"5": {ToAdd: []Entry{entry2, entry2ul, entry2diff},
+			Entries:   map[EntryName]Entry{entry2.Name: entry2diff},
+			Revisions: map[EntryName][]Entry{entry2.Name: {entry2, entry2ul, entry2diff}}},
+		"6": {ToAdd: []Entry{entry2, entry2ul, entry2},
+			Entries:   map[EntryName]Entry{entry2.Name: entry2},
+			Revisions: map[EntryName][]Entry{entry2.Name: {entry2, entry2ul, entry2}}},
 	}
 
 	for id, test := range tests {
@@ -39,8 +45,60 @@ func TestJournal_Add(t *testing.T) {
 			journal.Add(entry)
 		}
 
-		if !reflect.DeepEqual(journal, test.OutJournal) {
-			t.Errorf(testFail, journal, test.OutJournal, id)
+		if !reflect.DeepEqual(journal.Entries, test.Entries) {
+			t.Errorf(testFail, journal.Entries, test.Entries, id)
+		}
+		revisions := map[EntryName][]Entry{}
+		for name, revs := range journal.Revisions {
+			for i, rev := range revs {
+				if rev.Number != i+1 || rev.Hash != rev.Entry.Hash() || rev.Time.IsZero() {
+					t.Errorf("bad revision %d of %s: %+v Case: %q", i+1, name, rev, id)
+				}
+				revisions[name] = append(revisions[name], rev.Entry)
+			}
+		}
+		if !reflect.DeepEqual(revisions, test.Revisions) {
+			t.Errorf(testFail, revisions, test.Revisions, id)
+		}
+	}
+}
+
+func TestJournal_Revision(t *testing.T) {
+	entry1 := Entry{Name: "2019-01-01"}
+	entry1diff := Entry{Name: "2019-01-01", Sections: []Section{{Title: "a"}}}
+	entry2 := Entry{Name: "2019-01-02"}
+	at := time.Date(2019, 1, 1, 18, 0, 0, 0, time.UTC)
+
+	journal := NewJournal()
+	journal.AddAt(entry1, at)
+	journal.AddAt(entry1diff, at.Add(time.Hour))
+	// Entries put into the map directly have a single revision.
+	journal.Entries[entry2.Name] = entry2
+
+	tests := map[string]struct {
+		Name   EntryName
+		Number int
+		Rev    Revision
+		Found  bool
+	}{
+		"first":  {Name: "2019-01-01", Number: 1, Rev: Revision{1, at, entry1.Hash(), entry1}, Found: true},
+		"second": {Name: "2019-01-01", Number: 2, Rev: Revision{2, at.Add(time.Hour), entry1diff.Hash(), entry1diff}, Found: true},
+		"latest": {Name: "2019-01-01", Number: -1, Rev: Revision{2, at.Add(time.Hour), entry1diff.Hash(), entry1diff}, Found: true},
+		"direct": {Name: "2019-01-02", Number: -1, Rev: Revision{Number: 1, Hash: entry2.Hash(), Entry: entry2}, Found: true},
+		"past":   {Name: "2019-01-01", Number: 3, Found: false},
+		"none":   {Name: "2019-01-03", Number: -1, Found: false},
+	}
+
+	for id, test := range tests {
+		var rev Revision
+		var found bool
+		if test.Number == -1 {
+			rev, found = journal.LatestRevision(test.Name)
+		} else {
+			rev, found = journal.Revision(test.Name, test.Number)
+		}
+		if found != test.Found || !reflect.DeepEqual(rev, test.Rev) {
+			t.Errorf(testFail, rev, test.Rev, id)
 		}
 	}
 }
@@ -51,15 +109,14 @@ func TestJournal_Contains(t *testing.T) {
 	entry1 := Entry{Name: "2019-01-01", Sections: sections1}
 	entry2 := Entry{Name: "2019-01-02", Sections: sections2}
 	entry2ul := Entry{Name: "2019-01-02", Sections: sections2, Style: Underline}
-	entry2ulAdded := Entry{Name: "2019-01-021", Sections: sections2, Style: Underline}
 	journal1 := NewJournal()
 	journal1.Entries[entry1.Name] = entry1
 	journal2 := NewJournal()
 	journal2.Entries[entry1.Name] = entry1
 	journal2.Entries[entry2.Name] = entry2
 	journal3 := NewJournal()
-	journal3.Entries[entry2.Name] = entry2
-	journal3.Entries[entry2ulAdded.Name] = entry2ulAdded
+	journal3.Add(entry2)
+	journal3.Add(entry2ul)
 
 	tests := map[string]struct {
 		Journal *Journal
@@ -83,3 +140,157 @@ func TestJournal_Contains(t *testing.T) {
 		}
 	}
 }
+
+func TestJournal_ByHash(t *testing.T) {
+	entry1 := Entry{Name: "2019-01-01", Sections: []Section{{Title: "title1"}}}
+	entry2 := Entry{Name: "2019-01-02", Sections: []Section{{Title: "title2"}}}
+	journal := NewJournal()
+	journal.Add(entry1)
+
+	if _, ok := journal.ByHash(entry2.Hash()); ok {
+		t.Errorf("found an entry that was never added")
+	}
+	// Entries added through Add, or directly to the map, are both found.
+	journal.Add(entry2)
+	if e, ok := journal.ByHash(entry2.Hash()); !ok || !e.Equals(entry2) {
+		t.Errorf(testFail, e, entry2, "added")
+	}
+	entry3 := Entry{Name: "2019-01-03", Style: Underline}
+	journal.Entries[entry3.Name] = entry3
+	if !journal.Contains(entry3) {
+		t.Errorf("entry added to the map directly was not found")
+	}
+
+	// Finding entries does not change the journal, so it can be done from many goroutines at once.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			journal.Contains(entry1)
+			journal.Contains(entry3)
+		}()
+	}
+	wg.Wait()
+	if journal.indexed != 2 {
+		t.Errorf("finding entries rebuilt the index")
+	}
+	// An entry replaced in the map directly, or swapped for another under a new name, is found too.
+	replaced := Entry{Name: entry1.Name, Sections: []Section{{Title: "replaced"}}}
+	journal.Entries[entry1.Name] = replaced
+	if e, ok := journal.ByHash(replaced.Hash()); !ok || !e.Equals(replaced) {
+		t.Errorf(testFail, e, replaced, "replaced")
+	}
+	delete(journal.Entries, entry2.Name)
+	swapped := Entry{Name: "2019-01-05", Sections: []Section{{Title: "swapped"}}}
+	journal.Entries[swapped.Name] = swapped
+	if !journal.Contains(swapped) {
+		t.Errorf("entry swapped in for another was not found")
+	}
+	if journal.Contains(entry2) {
+		t.Errorf("entry deleted from the map was found")
+	}
+	delete(journal.Entries, swapped.Name)
+	journal.Entries[entry2.Name] = entry2
+	journal.Entries[entry1.Name] = entry1
+
+	// Adding an entry indexes those added to the map directly.
+	journal.Add(Entry{Name: "2019-01-04"})
+	if refs := journal.hashes[entry3.Hash()]; len(refs) != 1 || journal.indexed != 4 {
+		t.Errorf(testFail, refs, entry3.Name, "indexed on Add")
+	}
+}
+
+func TestJournal_Query(t *testing.T) {
+	journal := NewJournal()
+	journal.Add(Entry{Name: "2019-01-03", Sections: []Section{{Title: "Learn", Body: "#go"}}})
+	journal.Add(Entry{Name: "2019-01-01", Sections: []Section{{Title: "Do", Body: "#oncall"}}})
+	journal.Add(Entry{Name: "2019-01-02", Sections: []Section{{Title: "Do"}, {Title: "learn"}}})
+	journal.Add(Entry{Name: "2019-02-01", Sections: []Section{{Title: "Do", Body: "#OnCall #go"}}})
+	date := func(s string) Date {
+		d, err := ParseDate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := map[string]struct {
+		Journal *Journal
+		Names   []EntryName
+	}{
+		"all":           {Journal: journal, Names: []EntryName{"2019-01-01", "2019-01-02", "2019-01-03", "2019-02-01"}},
+		"between":       {Journal: journal.Between(date("2019-01-02"), date("2019-01-03")), Names: []EntryName{"2019-01-02", "2019-01-03"}},
+		"open between":  {Journal: journal.Between(date("2019-01-03"), Date{}), Names: []EntryName{"2019-01-03", "2019-02-01"}},
+		"with section":  {Journal: journal.WithSection("LEARN"), Names: []EntryName{"2019-01-02", "2019-01-03"}},
+		"with tag":      {Journal: journal.WithTag("#oncall"), Names: []EntryName{"2019-01-01", "2019-02-01"}},
+		"latest":        {Journal: journal.Latest(2), Names: []EntryName{"2019-01-03", "2019-02-01"}},
+		"latest of all": {Journal: journal.Latest(10), Names: journal.Names()},
+		"chained":       {Journal: journal.WithTag("go").Between(Date{}, date("2019-01-31")).Latest(1), Names: []EntryName{"2019-01-03"}},
+	}
+
+	for id, test := range tests {
+		var names []EntryName
+		for _, e := range test.Journal.Ordered() {
+			names = append(names, e.Name)
+		}
+		if !reflect.DeepEqual(names, test.Names) {
+			t.Errorf(testFail, names, test.Names, id)
+		}
+	}
+}
+
+func TestJournal_JSON(t *testing.T) {
+	at := time.Date(2019, 1, 1, 18, 0, 0, 0, time.UTC)
+	entry1 := Entry{Name: "2019-01-01", Sections: []Section{{Title: "Do", Body: "a"}}}
+	entry1diff := Entry{Name: "2019-01-01", Sections: []Section{{Title: "Do", Body: "b"}}}
+	journal := NewJournal()
+	journal.AddAt(entry1, at)
+	journal.AddAt(entry1diff, at)
+	journal.Entries["2019-01-02"] = Entry{Name: "2019-01-02"}
+	journal.Contains(Default)
+	journal.Timezone = "Europe/Berlin"
+
+	bts, err := json.Marshal(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"entries":{` +
+		`"2019-01-01":{"name":"2019-01-01","sections":[{"title":"Do","body":"b"}],"style":"pound","files":null},` +
+		`"2019-01-02":{"name":"2019-01-02","sections":null,"style":"pound","files":null}},` +
+		`"revisions":{"2019-01-01":[` +
+		`{"number":1,"time":"2019-01-01T18:00:00Z","hash":"` + entry1.Hash() + `","entry":` +
+		`{"name":"2019-01-01","sections":[{"title":"Do","body":"a"}],"style":"pound","files":null}},` +
+		`{"number":2,"time":"2019-01-01T18:00:00Z","hash":"` + entry1diff.Hash() + `"}]},` +
+		`"timezone":"Europe/Berlin"}`
+	if string(bts) != expected {
+		t.Errorf(testFail, string(bts), expected, "encoding")
+	}
+
+	decoded := NewJournal()
+	if err := json.Unmarshal(bts, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Entries, journal.Entries) || !reflect.DeepEqual(decoded.Revisions, journal.Revisions) ||
+		decoded.Timezone != journal.Timezone {
+		t.Errorf(testFail, decoded, journal, "decoding")
+	}
+	if !decoded.Contains(entry1) {
+		t.Errorf("decoded journal is missing the first revision")
+	}
+
+	// Journals without revisions decode as well.
+	old := NewJournal()
+	if err := json.Unmarshal([]byte(`{"entries":{"2019-01-01":{"name":"2019-01-01"}}}`), old); err != nil {
+		t.Fatal(err)
+	}
+	if rev, ok := old.LatestRevision("2019-01-01"); !ok || rev.Number != 1 {
+		t.Errorf("unexpected revision %+v", rev)
+	}
+
+	// Entries must be named after the date they were written on.
+	err = json.Unmarshal([]byte(`{"entries":{"2019-01-021":{"name":"2019-01-021"}}}`), NewJournal())
+	if expected := `bad entry name: invalid date "2019-01-021", dates must look like 2019-01-31`; err == nil || err.Error() != expected {
+		t.Errorf(testFail, err, expected, "bad name")
+	}
+}
@@ -0,0 +1,194 @@
+package entry
+
+import (
+	"strings"
+)
+
+// PrefixRule sends the body lines of a section starting with Prefix to the section titled Title.
+type PrefixRule struct {
+	Prefix string
+	Title  string
+}
+
+// RenameSection renames every section titled from (ignoring case) to to.
+// If the entry already has a section titled to, the renamed sections are merged into it as MergeSections does,
+// rather than leaving two sections with the same title.
+// It returns the number of sections renamed.
+func (e *Entry) RenameSection(from, to string) int {
+	renamed, taken := 0, false
+	for _, s := range e.Sections {
+		switch {
+		case strings.EqualFold(s.Title, from):
+			if s.Title != to {
+				renamed++
+			}
+		case strings.EqualFold(s.Title, to):
+			taken = true
+		}
+	}
+	if renamed > 0 && taken {
+		e.MergeSections([]string{from}, to)
+		return renamed
+	}
+	for i, s := range e.Sections {
+		if strings.EqualFold(s.Title, from) {
+			e.Sections[i].Title = to
+		}
+	}
+	return renamed
+}
+
+// MergeSections joins every section titled into or one of from (ignoring case) into a single section titled into.
+// The merged section takes the place of the first matching section, and the bodies are kept in order.
+// It returns false if the entry was left unchanged.
+func (e *Entry) MergeSections(from []string, into string) bool {
+	titles := map[string]struct{}{strings.ToLower(into): {}}
+	for _, f := range from {
+		titles[strings.ToLower(f)] = struct{}{}
+	}
+
+	var out []Section
+	var bodies []string
+	merged, at := 0, -1
+	for _, s := range e.Sections {
+		if _, ok := titles[strings.ToLower(s.Title)]; !ok {
+			out = append(out, s)
+			continue
+		}
+		if at == -1 {
+			at = len(out)
+			out = append(out, Section{Title: into})
+		} else {
+			merged++
+		}
+		if s.Body != "" {
+			bodies = append(bodies, s.Body)
+		}
+	}
+	if at == -1 || merged == 0 && e.Sections[at].Title == into {
+		// Nothing to merge with, and nothing to rename.
+		return false
+	}
+
+	out[at].Body = strings.Join(bodies, "\n\n")
+	e.Sections = out
+	return true
+}
+
+// SplitSection splits the section titled title (ignoring case) using rules.
+// Each top level line of the body goes to the section of the first rule whose prefix it starts with.
+// Indented and blank lines stay with the line before them, so nested lists are kept together.
+// Lines matching no rule stay in a section with the original title, which is dropped if it ends up empty.
+// Rule sections that already exist in the entry are appended to; otherwise they are added in rule order
+// where the original section was, if any lines went to them.
+// It returns false, leaving the entry unchanged, if the entry has no section titled title
+// or none of its lines go to a rule.
+func (e *Entry) SplitSection(title string, rules []PrefixRule) bool {
+	at := -1
+	for i, s := range e.Sections {
+		if strings.EqualFold(s.Title, title) {
+			at = i
+			break
+		}
+	}
+	if at == -1 {
+		return false
+	}
+
+	orig := e.Sections[at]
+	bodies := make([][]string, len(rules))
+	var rest []string
+	dest := &rest
+	for _, l := range strings.Split(orig.Body, "\n") {
+		if l != "" && !strings.HasPrefix(l, " ") && !strings.HasPrefix(l, "\t") {
+			dest = &rest
+			for i, r := range rules {
+				if strings.HasPrefix(l, r.Prefix) {
+					dest = &bodies[i]
+					break
+				}
+			}
+		}
+		*dest = append(*dest, l)
+	}
+	if len(rest) == len(strings.Split(orig.Body, "\n")) {
+		return false
+	}
+
+	var added []Section
+	for i, r := range rules {
+		body := strings.TrimSpace(strings.Join(bodies[i], "\n"))
+		if body == "" {
+			// No lines went to this rule, so it adds nothing.
+			continue
+		}
+		if j := e.sectionIndex(r.Title, at); j != -1 {
+			e.Sections[j].Body = joinBodies(e.Sections[j].Body, body)
+			continue
+		}
+		if k := sectionIndex(added, r.Title); k != -1 {
+			added[k].Body = joinBodies(added[k].Body, body)
+			continue
+		}
+		added = append(added, Section{Title: r.Title, Body: body})
+	}
+	if body := strings.TrimSpace(strings.Join(rest, "\n")); body != "" {
+		added = append(added, Section{Title: orig.Title, Body: body})
+	}
+
+	out := append([]Section{}, e.Sections[:at]...)
+	out = append(out, added...)
+	e.Sections = append(out, e.Sections[at+1:]...)
+	return true
+}
+
+// sectionIndex finds the section titled title (ignoring case), skipping the section at index skip.
+func (e Entry) sectionIndex(title string, skip int) int {
+	for i, s := range e.Sections {
+		if i != skip && strings.EqualFold(s.Title, title) {
+			return i
+		}
+	}
+	return -1
+}
+
+func sectionIndex(sections []Section, title string) int {
+	for i, s := range sections {
+		if strings.EqualFold(s.Title, title) {
+			return i
+		}
+	}
+	return -1
+}
+
+func joinBodies(b1, b2 string) string {
+	if b1 == "" {
+		return b2
+	} else if b2 == "" {
+		return b1
+	}
+	return b1 + "\n\n" + b2
+}
+
+// ChangedSections returns the titles of the sections added, changed or removed going from e to e2:
+// first those in e2, in order, then those only in e.
+func (e Entry) ChangedSections(e2 Entry) []string {
+	old := map[string]string{}
+	for _, s := range e.Sections {
+		old[s.Title] = s.Body
+	}
+	var changed []string
+	for _, s := range e2.Sections {
+		if body, ok := old[s.Title]; !ok || body != s.Body {
+			changed = append(changed, s.Title)
+		}
+		delete(old, s.Title)
+	}
+	for _, s := range e.Sections {
+		if _, ok := old[s.Title]; ok {
+			changed = append(changed, s.Title)
+			delete(old, s.Title)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,160 @@
+package entry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEntry_RenameSection(t *testing.T) {
+	tests := map[string]struct {
+		E       Entry
+		From    string
+		To      string
+		Out     Entry
+		Renamed int
+	}{
+		"rename": {
+			E:    Entry{Sections: []Section{{Title: "Do"}, {Title: "Learn", Body: "a"}}},
+			From: "Learn", To: "TIL",
+			Out:     Entry{Sections: []Section{{Title: "Do"}, {Title: "TIL", Body: "a"}}},
+			Renamed: 1},
+		"ignore case": {
+			E:    Entry{Sections: []Section{{Title: "learn"}}},
+			From: "Learn", To: "TIL",
+			Out:     Entry{Sections: []Section{{Title: "TIL"}}},
+			Renamed: 1},
+		"into an existing section": {
+			E:    Entry{Sections: []Section{{Title: "Do", Body: "a"}, {Title: "Learn", Body: "b"}, {Title: "til", Body: "c"}}},
+			From: "Learn", To: "TIL",
+			Out:     Entry{Sections: []Section{{Title: "Do", Body: "a"}, {Title: "TIL", Body: "b\n\nc"}}},
+			Renamed: 1},
+		"missing": {
+			E:    Entry{Sections: []Section{{Title: "Do"}}},
+			From: "Learn", To: "TIL",
+			Out:     Entry{Sections: []Section{{Title: "Do"}}},
+			Renamed: 0},
+	}
+
+	for id, test := range tests {
+		renamed := test.E.RenameSection(test.From, test.To)
+		if !reflect.DeepEqual(test.E, test.Out) {
+			t.Errorf(testFail, test.E, test.Out, id)
+		}
+		if renamed != test.Renamed {
+			t.Errorf(testFail, renamed, test.Renamed, id)
+		}
+	}
+}
+
+func TestEntry_MergeSections(t *testing.T) {
+	tests := map[string]struct {
+		E      Entry
+		From   []string
+		Into   string
+		Out    Entry
+		Merged bool
+	}{
+		"merge two": {
+			E: Entry{Sections: []Section{
+				{Title: "Do", Body: "a"}, {Title: "Learn", Body: "b"}, {Title: "Read", Body: "c"}}},
+			From: []string{"Read"}, Into: "Learn",
+			Out:    Entry{Sections: []Section{{Title: "Do", Body: "a"}, {Title: "Learn", Body: "b\n\nc"}}},
+			Merged: true},
+		"merge into new title": {
+			E: Entry{Sections: []Section{
+				{Title: "Read", Body: "c"}, {Title: "Do", Body: "a"}, {Title: "Learn"}}},
+			From: []string{"Read", "Learn"}, Into: "TIL",
+			Out:    Entry{Sections: []Section{{Title: "TIL", Body: "c"}, {Title: "Do", Body: "a"}}},
+			Merged: true},
+		"nothing to merge": {
+			E:    Entry{Sections: []Section{{Title: "Do", Body: "a"}}},
+			From: []string{"Read"}, Into: "Do",
+			Out:    Entry{Sections: []Section{{Title: "Do", Body: "a"}}},
+			Merged: false},
+	}
+
+	for id, test := range tests {
+		merged := test.E.MergeSections(test.From, test.Into)
+		if !reflect.DeepEqual(test.E, test.Out) {
+			t.Errorf(testFail, test.E, test.Out, id)
+		}
+		if merged != test.Merged {
+			t.Errorf(testFail, merged, test.Merged, id)
+		}
+	}
+}
+
+func TestEntry_SplitSection(t *testing.T) {
+	rules := []PrefixRule{{Prefix: "- [x]", Title: "Done"}, {Prefix: "- [ ]", Title: "Planned"}}
+
+	tests := map[string]struct {
+		E     Entry
+		Title string
+		Out   Entry
+		Split bool
+	}{
+		"split tasks": {
+			E: Entry{Sections: []Section{
+				{Title: "Do", Body: "- [x] one\n  - detail\n- [ ] two\n- [x] three"}, {Title: "Learn"}}},
+			Title: "Do",
+			Out: Entry{Sections: []Section{
+				{Title: "Done", Body: "- [x] one\n  - detail\n- [x] three"},
+				{Title: "Planned", Body: "- [ ] two"},
+				{Title: "Learn"}}},
+			Split: true},
+		"keep unmatched lines": {
+			E:     Entry{Sections: []Section{{Title: "Do", Body: "notes\n\n- [ ] two"}}},
+			Title: "do",
+			Out: Entry{Sections: []Section{
+				{Title: "Planned", Body: "- [ ] two"}, {Title: "Do", Body: "notes"}}},
+			Split: true},
+		"append to existing": {
+			E: Entry{Sections: []Section{
+				{Title: "Done", Body: "- [x] old"}, {Title: "Do", Body: "- [x] new"}}},
+			Title: "Do",
+			Out: Entry{Sections: []Section{
+				{Title: "Done", Body: "- [x] old\n\n- [x] new"}}},
+			Split: true},
+		"no lines for any rule": {
+			E:     Entry{Sections: []Section{{Title: "Do", Body: "\nnotes\n"}, {Title: "Learn"}}},
+			Title: "Do",
+			Out:   Entry{Sections: []Section{{Title: "Do", Body: "\nnotes\n"}, {Title: "Learn"}}},
+			Split: false},
+		"missing": {
+			E:     Entry{Sections: []Section{{Title: "Learn"}}},
+			Title: "Do",
+			Out:   Entry{Sections: []Section{{Title: "Learn"}}},
+			Split: false},
+	}
+
+	for id, test := range tests {
+		split := test.E.SplitSection(test.Title, rules)
+		if !reflect.DeepEqual(test.E, test.Out) {
+			t.Errorf(testFail, test.E, test.Out, id)
+		}
+		if split != test.Split {
+			t.Errorf(testFail, split, test.Split, id)
+		}
+	}
+}
+
+func TestEntry_ChangedSections(t *testing.T) {
+	e := Entry{Sections: []Section{{Title: "Do", Body: "a"}, {Title: "Learn", Body: "b"}, {Title: "Blockers"}}}
+
+	tests := map[string]struct {
+		E2      Entry
+		Changed []string
+	}{
+		"same":  {E2: e},
+		"moved": {E2: Entry{Sections: []Section{{Title: "Learn", Body: "b"}, {Title: "Blockers"}, {Title: "Do", Body: "a"}}}},
+		"changed": {
+			E2:      Entry{Sections: []Section{{Title: "Do", Body: "a, b"}, {Title: "Learn", Body: "b"}, {Title: "Ideas"}}},
+			Changed: []string{"Do", "Ideas", "Blockers"}},
+	}
+
+	for id, test := range tests {
+		if changed := e.ChangedSections(test.E2); !reflect.DeepEqual(changed, test.Changed) {
+			t.Errorf(testFail, changed, test.Changed, id)
+		}
+	}
+}
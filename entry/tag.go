@@ -0,0 +1,38 @@
+package entry
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var tagRegex = regexp.MustCompile(`(^|[\s(\[,])#([A-Za-z][\w-]*)`)
+
+// Tags lists the distinct #tags used in the bodies of the entry's sections, lowercased and sorted.
+func (e Entry) Tags() []string {
+	seen := map[string]struct{}{}
+	for _, s := range e.Sections {
+		for _, tag := range FindTags(s.Body) {
+			seen[tag] = struct{}{}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// FindTags lists the distinct #tags used in text, lowercased and sorted.
+func FindTags(text string) []string {
+	seen := map[string]struct{}{}
+	for _, m := range tagRegex.FindAllStringSubmatch(text, -1) {
+		seen[strings.ToLower(m[2])] = struct{}{}
+	}
+	return sortedKeys(seen)
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,40 @@
+package entry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindTags(t *testing.T) {
+	tests := map[string]struct {
+		In   string
+		Tags []string
+	}{
+		"none":       {In: "no tags here", Tags: nil},
+		"one":        {In: "paged at 3am #oncall", Tags: []string{"oncall"}},
+		"repeated":   {In: "#OnCall and #oncall", Tags: []string{"oncall"}},
+		"sorted":     {In: "- #db-migration\n- (#api) #Deploy", Tags: []string{"api", "db-migration", "deploy"}},
+		"not a tag":  {In: "issue#12 and # heading and #1", Tags: nil},
+		"list start": {In: "#release notes", Tags: []string{"release"}},
+	}
+
+	for id, test := range tests {
+		tags := FindTags(test.In)
+		if !reflect.DeepEqual(tags, test.Tags) {
+			t.Errorf(testFail, tags, test.Tags, id)
+		}
+	}
+}
+
+func TestEntry_Public(t *testing.T) {
+	e := Entry{Name: "2019-01-01", Sections: []Section{{Title: "Do", Body: "a"}, {Title: "Learn", Body: "b"}}}
+	expected := Entry{Name: "2019-01-01", Sections: []Section{{Title: "Learn", Body: "b"}}}
+
+	public := e.Public(map[string]struct{}{"learn": {}})
+	if !reflect.DeepEqual(public, expected) {
+		t.Errorf(testFail, public, expected, "public")
+	}
+	if len(e.Sections) != 2 {
+		t.Errorf("Public changed the original entry")
+	}
+}
@@ -0,0 +1,4 @@
+package filesystem
+
+// TakeoverSuffix lets the tests outside the package leave a takeover file in the way.
+const TakeoverSuffix = takeoverSuffix
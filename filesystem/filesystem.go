@@ -1,41 +1,18 @@
 package filesystem
 
 import (
-	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"regexp"
+	"io/fs"
 	"time"
-)
-
-func Latest() string {
-	files, err := ioutil.ReadDir("./")
-	if err != nil {
-		return ""
-	}
-
-	regex := regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
-	lastDirMatch := ""
-	for _, f := range files {
-		if f.IsDir() && regex.MatchString(f.Name()) {
-			lastDirMatch = f.Name()
-		}
-	}
 
-	if lastDirMatch != "" {
-		return filepath.Join(lastDirMatch, fmt.Sprintf("%s.md", lastDirMatch))
-	}
-	return ""
-}
+	"github.com/ifo/dev.journal/entry"
+)
 
 func DateString(t time.Time) string {
-	y, m, d := t.Date()
-	return fmt.Sprintf("%d-%02d-%02d", y, m, d)
+	return entry.DateOf(t).String()
 }
 
-func ListFiles(dir string) ([]string, error) {
-	files, err := ioutil.ReadDir(dir)
+func ListFiles(fsys fs.FS, dir string) ([]string, error) {
+	files, err := fs.ReadDir(fsys, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -47,8 +24,8 @@ func ListFiles(dir string) ([]string, error) {
 	return fileList, nil
 }
 
-func ListDirs(dir string) ([]string, error) {
-	files, err := ioutil.ReadDir(dir)
+func ListDirs(fsys fs.FS, dir string) ([]string, error) {
+	files, err := fs.ReadDir(fsys, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -63,24 +40,6 @@ func ListDirs(dir string) ([]string, error) {
 	return fileList, nil
 }
 
-func ReadFile(file string) ([]byte, error) {
-	return ioutil.ReadFile(file)
-}
-
-func EnsureFolderExists(folder string) error {
-	if _, err := os.Stat(folder); os.IsNotExist(err) {
-		err = os.Mkdir(folder, os.ModePerm)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func SafeWriteFile(path string, b []byte) error {
-	if _, err := os.Stat(path); !os.IsNotExist(err) {
-		return fmt.Errorf("file at path: %s already exists", path)
-	}
-
-	return ioutil.WriteFile(path, b, 0644)
+func EnsureFolderExists(fsys FS, folder string) error {
+	return fsys.MkdirAll(folder)
 }
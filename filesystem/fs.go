@@ -0,0 +1,103 @@
+package filesystem
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FS is where a journal is kept: the io/fs read operations, plus the writes devj makes.
+// As in io/fs, names are slash separated and relative to the journal root, with "." for the root itself.
+type FS interface {
+	fs.ReadDirFS
+	fs.ReadFileFS
+	fs.StatFS
+
+	// MkdirAll creates the folder name along with any missing parents.
+	MkdirAll(name string) error
+	// WriteFile replaces the file name with b, so the file has either its old or its new contents,
+	// even if writing is cut short.
+	WriteFile(name string, b []byte) error
+	// CreateFile writes b to a new file name, like WriteFile, failing with fs.ErrExist if there
+	// is already a file there.
+	CreateFile(name string, b []byte) error
+	// Rename moves a file or a folder.
+	Rename(oldname, newname string) error
+	// Remove removes a file or an empty folder.
+	Remove(name string) error
+}
+
+// OS returns the FS of the folder root on the local disk.
+func OS(root string) FS {
+	return osFS(root)
+}
+
+type osFS string
+
+// path returns the name in the folder, or fails if name is not a valid io/fs name.
+func (root osFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(root), filepath.FromSlash(name)), nil
+}
+
+func (root osFS) Open(name string) (fs.File, error) {
+	return os.DirFS(string(root)).Open(name)
+}
+
+func (root osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(os.DirFS(string(root)), name)
+}
+
+func (root osFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(os.DirFS(string(root)), name)
+}
+
+func (root osFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(os.DirFS(string(root)), name)
+}
+
+func (root osFS) MkdirAll(name string) error {
+	p, err := root.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, os.ModePerm)
+}
+
+func (root osFS) WriteFile(name string, b []byte) error {
+	p, err := root.path("write", name)
+	if err != nil {
+		return err
+	}
+	return writeFile(p, b)
+}
+
+func (root osFS) CreateFile(name string, b []byte) error {
+	p, err := root.path("create", name)
+	if err != nil {
+		return err
+	}
+	return createFile(p, b)
+}
+
+func (root osFS) Rename(oldname, newname string) error {
+	from, err := root.path("rename", oldname)
+	if err != nil {
+		return err
+	}
+	to, err := root.path("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (root osFS) Remove(name string) error {
+	p, err := root.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
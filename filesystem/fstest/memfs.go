@@ -0,0 +1,192 @@
+// Package fstest provides an in-memory filesystem.FS for the tests of packages working on journals.
+package fstest
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	mapfs "testing/fstest"
+	"time"
+
+	"github.com/ifo/dev.journal/filesystem"
+)
+
+// MemFS is a filesystem.FS kept in memory.
+// It is safe for concurrent use.
+type MemFS struct {
+	mu    sync.Mutex
+	files mapfs.MapFS
+}
+
+var _ filesystem.FS = (*MemFS)(nil)
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{files: mapfs.MapFS{}}
+}
+
+// Files that are written are never changed afterwards, only replaced, so they can be read without holding the lock.
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.Open(name)
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.ReadDir(name)
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.ReadFile(name)
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files.Stat(name)
+}
+
+func (m *MemFS) MkdirAll(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if info, err := m.files.Stat(dir); err == nil && !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+	}
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if _, ok := m.files[dir]; !ok {
+			m.files[dir] = &mapfs.MapFile{Mode: fs.ModeDir | 0755, ModTime: now()}
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) WriteFile(name string, b []byte) error {
+	return m.write("write", name, b, false)
+}
+
+func (m *MemFS) CreateFile(name string, b []byte) error {
+	return m.write("create", name, b, true)
+}
+
+func (m *MemFS) write(op, name string, b []byte, create bool) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkDir(op, path.Dir(name)); err != nil {
+		return err
+	}
+	if old, ok := m.files[name]; ok && (create || old.Mode.IsDir()) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	m.files[name] = &mapfs.MapFile{Data: append([]byte(nil), b...), Mode: 0644, ModTime: now()}
+	m.touch(path.Dir(name))
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || !fs.ValidPath(newname) {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, err := m.files.Stat(oldname)
+	if err != nil {
+		return err
+	}
+	if err := m.checkDir("rename", path.Dir(newname)); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if _, err := m.files.Stat(newname); err == nil {
+			return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+		}
+	}
+	for name, f := range m.files {
+		if name == oldname {
+			delete(m.files, name)
+			m.files[newname] = f
+		} else if strings.HasPrefix(name, oldname+"/") {
+			delete(m.files, name)
+			m.files[newname+strings.TrimPrefix(name, oldname)] = f
+		}
+	}
+	if info.IsDir() {
+		if _, ok := m.files[newname]; !ok {
+			m.files[newname] = &mapfs.MapFile{Mode: fs.ModeDir | 0755, ModTime: info.ModTime()}
+		}
+	}
+	m.touch(path.Dir(oldname))
+	m.touch(path.Dir(newname))
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, err := m.files.Stat(name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() {
+		if entries, _ := m.files.ReadDir(name); len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	delete(m.files, name)
+	m.touch(path.Dir(name))
+	return nil
+}
+
+// Names lists the files in m, in order. It is mostly useful in tests.
+func (m *MemFS) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name, f := range m.files {
+		if !f.Mode.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkDir fails unless dir is a folder.
+func (m *MemFS) checkDir(op, dir string) error {
+	info, err := m.files.Stat(dir)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: dir, Err: fs.ErrNotExist}
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: op, Path: dir, Err: errors.New("not a directory")}
+	}
+	return nil
+}
+
+// touch updates the modification time of dir, as adding or removing a file in it does on disk.
+func (m *MemFS) touch(dir string) {
+	if dir == "." {
+		return
+	}
+	m.files[dir] = &mapfs.MapFile{Mode: fs.ModeDir | 0755, ModTime: now()}
+}
+
+// now is the time to give changed files, without the monotonic clock reading a file's time on disk has no use for.
+func now() time.Time {
+	return time.Now().Round(0)
+}
@@ -0,0 +1,268 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+// DefaultLayout keeps each entry in a folder of its own, named after its date.
+const DefaultLayout = "{date}/{date}.md"
+
+// layoutTokens are the parts of a date a layout can use, and what each looks like.
+var layoutTokens = map[string]string{
+	"{date}": `\d{4}-\d{2}-\d{2}`,
+	"{yyyy}": `\d{4}`,
+	"{mm}":   `\d{2}`,
+	"{dd}":   `\d{2}`,
+}
+
+var layoutTokenRegex = regexp.MustCompile(`\{[^}]*\}`)
+
+// Layout is where the entries of a journal are kept, written as a slash separated path pattern
+// relative to the journal root, such as "{yyyy}/{mm}/{dd}.md". Patterns can use:
+//
+//	{date}  the date, as YYYY-MM-DD
+//	{yyyy}  the year
+//	{mm}    the month, from 01 to 12
+//	{dd}    the day of the month, from 01 to 31
+//
+// An entry's attachments are kept in the folder of its file, if no other entry shares it,
+// or otherwise in a folder named after its file without the ".md", such as "2019/01/31/".
+// The zero Layout is DefaultLayout.
+type Layout struct {
+	pattern  string
+	segments []*regexp.Regexp
+}
+
+// ParseLayout checks that pattern names a different markdown file for every date.
+func ParseLayout(pattern string) (Layout, error) {
+	clean := path.Clean(pattern)
+	switch {
+	case pattern == "":
+		return Layout{}, fmt.Errorf("empty layout")
+	case clean != pattern || path.IsAbs(pattern) || strings.HasPrefix(pattern, ".."):
+		return Layout{}, fmt.Errorf("layout %q must be a clean path inside the journal", pattern)
+	case !strings.HasSuffix(pattern, ".md"):
+		return Layout{}, fmt.Errorf("layout %q must end in .md", pattern)
+	}
+
+	used := map[string]bool{}
+	for _, tok := range layoutTokenRegex.FindAllString(pattern, -1) {
+		if _, ok := layoutTokens[tok]; !ok {
+			return Layout{}, fmt.Errorf("layout %q uses unknown %s, use {date}, {yyyy}, {mm} or {dd}", pattern, tok)
+		}
+		used[tok] = true
+	}
+	if !used["{date}"] && !(used["{yyyy}"] && used["{mm}"] && used["{dd}"]) {
+		return Layout{}, fmt.Errorf("layout %q must use {date}, or all of {yyyy}, {mm} and {dd}", pattern)
+	}
+
+	l := Layout{pattern: pattern}
+	for _, seg := range strings.Split(pattern, "/") {
+		re, last := "^", 0
+		for _, loc := range layoutTokenRegex.FindAllStringIndex(seg, -1) {
+			re += regexp.QuoteMeta(seg[last:loc[0]]) + layoutTokens[seg[loc[0]:loc[1]]]
+			last = loc[1]
+		}
+		l.segments = append(l.segments, regexp.MustCompile(re+regexp.QuoteMeta(seg[last:])+"$"))
+	}
+	return l, nil
+}
+
+func (l Layout) orDefault() Layout {
+	if l.pattern == "" {
+		l, _ = ParseLayout(DefaultLayout)
+	}
+	return l
+}
+
+// String returns the layout's pattern.
+func (l Layout) String() string {
+	return l.orDefault().pattern
+}
+
+// Path is the name of the entry file for date in the journal's FS.
+func (l Layout) Path(date entry.Date) string {
+	s := date.String()
+	return strings.NewReplacer("{date}", s, "{yyyy}", s[:4], "{mm}", s[5:7], "{dd}", s[8:]).Replace(l.String())
+}
+
+// Dir is the name of the folder holding the attachments of the entry for date in the journal's FS.
+func (l Layout) Dir(date entry.Date) string {
+	dir := path.Dir(l.String())
+	if strings.Contains(dir, "{date}") || strings.Contains(dir, "{dd}") {
+		return path.Dir(l.Path(date))
+	}
+	return strings.TrimSuffix(l.Path(date), ".md")
+}
+
+// Date returns the date of the entry file named p in the journal's FS.
+func (l Layout) Date(p string) (entry.Date, error) {
+	l = l.orDefault()
+	segs := strings.Split(p, "/")
+	patterns := strings.Split(l.String(), "/")
+	if len(segs) != len(patterns) {
+		return entry.Date{}, fmt.Errorf("%s does not match the layout %s", p, l)
+	}
+
+	values := map[string]string{}
+	for i, seg := range segs {
+		if !l.segments[i].MatchString(seg) {
+			return entry.Date{}, fmt.Errorf("%s does not match the layout %s", p, l)
+		}
+		// Read each token off the segment, from left to right.
+		pattern := patterns[i]
+		for pattern != "" {
+			loc := layoutTokenRegex.FindStringIndex(pattern)
+			if loc == nil {
+				break
+			}
+			seg = seg[loc[0]:]
+			tok := pattern[loc[0]:loc[1]]
+			n := len(tok) - 2
+			if tok == "{date}" {
+				n = 10
+			}
+			if old, ok := values[tok]; ok && old != seg[:n] {
+				return entry.Date{}, fmt.Errorf("%s uses two different dates", p)
+			}
+			values[tok] = seg[:n]
+			seg, pattern = seg[n:], pattern[loc[1]:]
+		}
+	}
+
+	s := values["{date}"]
+	if s == "" {
+		s = values["{yyyy}"] + "-" + values["{mm}"] + "-" + values["{dd}"]
+	}
+	date, err := entry.ParseDate(s)
+	if err != nil {
+		return entry.Date{}, fmt.Errorf("bad entry %s: %v", p, err)
+	}
+	for tok, want := range map[string]string{"{yyyy}": s[:4], "{mm}": s[5:7], "{dd}": s[8:]} {
+		if v, ok := values[tok]; ok && v != want {
+			return entry.Date{}, fmt.Errorf("%s uses two different dates", p)
+		}
+	}
+	return date, nil
+}
+
+// List finds every entry in the journal, and returns their dates in order.
+// Files and folders that do not match the layout, such as backups, are skipped,
+// but an entry file matching it with a day that does not exist, such as 2019-13-45, is an error.
+func (l Layout) List(fsys fs.FS) ([]entry.Date, error) {
+	paths, err := l.paths(fsys)
+	if err != nil {
+		return nil, err
+	}
+	var dates []entry.Date
+	for _, p := range paths {
+		date, err := l.Date(p)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, k int) bool { return dates[i].Before(dates[k]) })
+	return dates, nil
+}
+
+// Latest returns the name of the newest entry file in the journal, or "" if there is none.
+// Entries dated after today are not considered.
+func (l Layout) Latest(fsys fs.FS, today entry.Date) string {
+	date, ok := l.LatestBefore(fsys, today.AddDays(1))
+	if !ok {
+		return ""
+	}
+	return l.Path(date)
+}
+
+// LatestBefore returns the date of the newest entry in the journal written before date.
+// A zero date considers every entry, future ones included.
+// Unlike List, entries with invalid dates are skipped rather than failing, as are unreadable folders.
+func (l Layout) LatestBefore(fsys fs.FS, date entry.Date) (entry.Date, bool) {
+	return l.latest(fsys, func(d entry.Date) bool { return date.IsZero() || d.Before(date) })
+}
+
+// Previous returns the date of the newest entry in the journal written on a working day before date,
+// which is the day to look back on at the start of date.
+func (l Layout) Previous(fsys fs.FS, date entry.Date) (entry.Date, bool) {
+	return l.latest(fsys, func(d entry.Date) bool { return d.Before(date) && !d.IsWeekend() })
+}
+
+func (l Layout) latest(fsys fs.FS, keep func(entry.Date) bool) (entry.Date, bool) {
+	paths, _ := l.paths(fsys)
+	var latest entry.Date
+	for _, p := range paths {
+		if d, err := l.Date(p); err == nil && keep(d) && latest.Before(d) {
+			latest = d
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+// paths lists the files in the journal matching the layout.
+// Folders that cannot be read are skipped, but the first such error is returned along with the paths.
+func (l Layout) paths(fsys fs.FS) ([]string, error) {
+	l = l.orDefault()
+	var firstErr error
+	paths := []string{"."}
+	for i, seg := range l.segments {
+		var next []string
+		for _, p := range paths {
+			var names []string
+			var err error
+			if i == len(l.segments)-1 {
+				names, err = ListFiles(fsys, p)
+			} else {
+				names, err = ListDirs(fsys, p)
+			}
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			for _, name := range names {
+				if seg.MatchString(name) {
+					next = append(next, path.Join(p, name))
+				}
+			}
+		}
+		paths = next
+	}
+
+	// Only files count, not folders named like entry files.
+	files := paths[:0]
+	for _, p := range paths {
+		if info, err := fs.Stat(fsys, p); err == nil && !info.IsDir() {
+			files = append(files, p)
+		}
+	}
+	return files, firstErr
+}
+
+// Exists reports whether the entry for date is in the journal.
+func (l Layout) Exists(fsys fs.FS, date entry.Date) bool {
+	info, err := fs.Stat(fsys, l.Path(date))
+	return err == nil && !info.IsDir()
+}
+
+func (l Layout) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Layout) UnmarshalText(text []byte) error {
+	parsed, err := ParseLayout(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
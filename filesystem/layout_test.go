@@ -0,0 +1,137 @@
+package filesystem_test
+
+import (
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+const testFail = `Actual: "%v" Expected: "%v" Case: %q`
+
+func TestParseLayout(t *testing.T) {
+	tests := map[string]struct {
+		Err string
+	}{
+		"{date}/{date}.md":            {},
+		"{yyyy}/{mm}/{dd}.md":         {},
+		"{yyyy}/{date}/index.md":      {},
+		"journal/{yyyy}-{mm}-{dd}.md": {},
+		"":                            {Err: "empty layout"},
+		"{date}/{date}.txt":           {Err: `layout "{date}/{date}.txt" must end in .md`},
+		"../{date}.md":                {Err: `layout "../{date}.md" must be a clean path inside the journal`},
+		"/{date}.md":                  {Err: `layout "/{date}.md" must be a clean path inside the journal`},
+		"{yyyy}/{mm}.md":              {Err: `layout "{yyyy}/{mm}.md" must use {date}, or all of {yyyy}, {mm} and {dd}`},
+		"{week}/{date}.md":            {Err: `layout "{week}/{date}.md" uses unknown {week}, use {date}, {yyyy}, {mm} or {dd}`},
+	}
+
+	for pattern, test := range tests {
+		_, err := filesystem.ParseLayout(pattern)
+		if err != nil && err.Error() != test.Err || err == nil && test.Err != "" {
+			t.Errorf(testFail, err, test.Err, pattern)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	date, _ := entry.ParseDate("2019-01-31")
+
+	tests := map[string]struct {
+		Path, Dir string
+	}{
+		"":                        {Path: "2019-01-31/2019-01-31.md", Dir: "2019-01-31"},
+		"{yyyy}/{mm}/{dd}.md":     {Path: "2019/01/31.md", Dir: "2019/01/31"},
+		"{yyyy}/{date}/index.md":  {Path: "2019/2019-01-31/index.md", Dir: "2019/2019-01-31"},
+		"{yyyy}/{mm}/{dd}/day.md": {Path: "2019/01/31/day.md", Dir: "2019/01/31"},
+	}
+
+	for pattern, test := range tests {
+		var l filesystem.Layout
+		if pattern != "" {
+			var err error
+			if l, err = filesystem.ParseLayout(pattern); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if path := l.Path(date); path != test.Path {
+			t.Errorf(testFail, path, test.Path, pattern)
+		}
+		if dir := l.Dir(date); dir != test.Dir {
+			t.Errorf(testFail, dir, test.Dir, pattern)
+		}
+		if d, err := l.Date(test.Path); err != nil || d != date {
+			t.Errorf(testFail, d, date, pattern)
+		}
+	}
+}
+
+func TestLayout_Date(t *testing.T) {
+	l, _ := filesystem.ParseLayout("{yyyy}/{date}.md")
+
+	tests := map[string]struct {
+		Err string
+	}{
+		"2019/2019-01-31.md": {},
+		"2019/2019-13-31.md": {Err: `bad entry 2019/2019-13-31.md: invalid date "2019-13-31", there is no month 13`},
+		"2018/2019-01-31.md": {Err: "2018/2019-01-31.md uses two different dates"},
+		"2019-01-31.md":      {Err: "2019-01-31.md does not match the layout {yyyy}/{date}.md"},
+		"2019/backup.md":     {Err: "2019/backup.md does not match the layout {yyyy}/{date}.md"},
+	}
+
+	for path, test := range tests {
+		_, err := l.Date(path)
+		if err != nil && err.Error() != test.Err || err == nil && test.Err != "" {
+			t.Errorf(testFail, err, test.Err, path)
+		}
+	}
+}
+
+func TestLayout_Latest(t *testing.T) {
+	fsys := fstest.NewMemFS()
+	// 2019-01-04 is a Friday. Only the first four folders hold entries.
+	for _, dir := range []string{"2019-01-04", "2019-01-05", "2019-01-07", "2099-01-01",
+		"2019-01-08", "2019-13-45", "2099-01-01-draft", "archive"} {
+		if err := fsys.MkdirAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		if dir == "2019-01-08" {
+			continue
+		}
+		if err := fsys.WriteFile(dir+"/"+dir+".md", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var l filesystem.Layout
+	date := func(s string) entry.Date {
+		d, _ := entry.ParseDate(s)
+		return d
+	}
+
+	tests := map[string]struct {
+		Date, Expected entry.Date
+	}{
+		"latest":          {Date: dropFound(l.LatestBefore(fsys, date("2019-02-01"))), Expected: date("2019-01-07")},
+		"latest before":   {Date: dropFound(l.LatestBefore(fsys, date("2019-01-07"))), Expected: date("2019-01-05")},
+		"future":          {Date: dropFound(l.LatestBefore(fsys, entry.Date{})), Expected: date("2099-01-01")},
+		"previous monday": {Date: dropFound(l.Previous(fsys, date("2019-01-07"))), Expected: date("2019-01-04")},
+		"previous":        {Date: dropFound(l.Previous(fsys, date("2019-01-09"))), Expected: date("2019-01-07")},
+		"none":            {Date: dropFound(l.Previous(fsys, date("2019-01-04"))), Expected: entry.Date{}},
+	}
+
+	for id, test := range tests {
+		if test.Date != test.Expected {
+			t.Errorf(testFail, test.Date, test.Expected, id)
+		}
+	}
+	if latest := l.Latest(fsys, entry.Today()); latest != "2019-01-07/2019-01-07.md" {
+		t.Errorf(testFail, latest, "2019-01-07/2019-01-07.md", "latest path")
+	}
+	if _, err := l.List(fsys); err == nil {
+		t.Errorf("listed a journal with an invalid date")
+	}
+}
+
+func dropFound(d entry.Date, found bool) entry.Date {
+	return d
+}
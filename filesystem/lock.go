@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockFile is the name of the lock file kept in a journal root while it is being written to.
+const LockFile = ".devj-lock"
+
+// LockWait is how long Lock waits for another process to unlock a journal by default.
+const LockWait = 5 * time.Second
+
+// Lock takes the advisory lock of the journal in the folder dir of fsys, waiting up to wait for whoever holds it.
+// The lock is a file holding the process ID of its holder, so a lock left behind by a process that
+// no longer runs is taken over, as takeOver describes. The returned function releases the lock.
+func Lock(fsys FS, dir string, wait time.Duration) (func() error, error) {
+	name := path.Join(dir, LockFile)
+	unlock := func() error { return fsys.Remove(name) }
+	deadline := time.Now().Add(wait)
+	released := false
+	for {
+		err := fsys.CreateFile(name, lockContents())
+		if err == nil {
+			return unlock, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		pid, since, err := lockHolder(fsys, name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !released:
+			// The lock was released after CreateFile failed, so it is tried again straight away, once.
+			released = true
+			continue
+		case err == nil && !processRuns(pid):
+			// Whoever held the lock is gone.
+			took, err := takeOver(fsys, name, pid)
+			if err != nil {
+				return nil, err
+			}
+			if took {
+				return unlock, nil
+			}
+		}
+		released = false
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return nil, fmt.Errorf("journal is locked: %v", err)
+			}
+			if _, err := fsys.Stat(name + takeoverSuffix); err == nil && !processRuns(pid) {
+				return nil, fmt.Errorf("journal is locked by process %d, which is gone, but %s%s is in the way of taking it over; remove it if it is stale",
+					pid, name, takeoverSuffix)
+			}
+			return nil, fmt.Errorf("journal is locked by process %d since %s; remove %s if it is stale",
+				pid, since.Format("15:04:05"), name)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// takeoverSuffix names the file that processes taking over a lock take turns with.
+const takeoverSuffix = ".takeover"
+
+// takeOver replaces the lock file name, held by the process pid that is gone, with one held by this process,
+// and reports whether it did. Processes taking over the same lock take turns by creating a second lock file,
+// and each checks that the lock is still held by pid before removing it, so none of them can remove
+// a lock another has just taken. A second lock file left by a process that stopped while taking over
+// has to be removed by hand.
+func takeOver(fsys FS, name string, pid int) (bool, error) {
+	turn := name + takeoverSuffix
+	if err := fsys.CreateFile(turn, lockContents()); errors.Is(err, fs.ErrExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer fsys.Remove(turn)
+
+	if holder, _, err := lockHolder(fsys, name); err != nil || holder != pid {
+		return false, nil
+	}
+	if err := fsys.Remove(name); err != nil {
+		return false, err
+	}
+	err := fsys.CreateFile(name, lockContents())
+	if errors.Is(err, fs.ErrExist) {
+		// Someone else locked the journal as soon as it was unlocked.
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func lockContents() []byte {
+	return []byte(fmt.Sprintf("%d\n", os.Getpid()))
+}
+
+func lockHolder(fsys FS, name string) (int, time.Time, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	bts, err := fsys.ReadFile(name)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(bts)))
+	if err != nil {
+		return 0, info.ModTime(), fmt.Errorf("lock file %s has no process ID", name)
+	}
+	return pid, info.ModTime(), nil
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+)
+
+// processRuns reports whether the process with the ID pid is running.
+// If that cannot be told, the process is assumed to be running.
+func processRuns(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 only checks that the process could be signalled.
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package filesystem
+
+import "os"
+
+// processRuns reports whether the process with the ID pid is running.
+// Windows has no signal 0, but FindProcess opens the process, which fails once it is gone.
+func processRuns(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// SafeWriteFile writes b to a new file at name, failing if there is already a file there.
+func SafeWriteFile(fsys FS, name string, b []byte) error {
+	if err := fsys.CreateFile(name, b); errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("file at path: %s already exists", name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// OverwriteFile replaces the file at name with b, after copying the file already there, if any,
+// into backupDir. The copy keeps name as its path relative to backupDir.
+func OverwriteFile(fsys FS, name string, b []byte, backupDir string) error {
+	if _, err := fs.Stat(fsys, name); err == nil {
+		if err := BackupFile(fsys, name, backupDir); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return fsys.WriteFile(name, b)
+}
+
+// BackupFile copies the file at name into dir, keeping name as its path relative to dir.
+func BackupFile(fsys FS, name, dir string) error {
+	bts, err := fsys.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	dest := path.Join(dir, name)
+	if err := EnsureFolderExists(fsys, path.Dir(dest)); err != nil {
+		return err
+	}
+	return SafeWriteFile(fsys, dest, bts)
+}
+
+// createFile writes b to a new file at p on disk, failing if there is already one there.
+// The file either appears whole or not at all.
+func createFile(p string, b []byte) error {
+	tmp, err := writeTemp(p, b)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	// Linking fails if there is a file at p, where renaming would replace it.
+	if err := os.Link(tmp, p); err != nil {
+		if os.IsExist(err) {
+			return &fs.PathError{Op: "create", Path: p, Err: fs.ErrExist}
+		}
+		// Some filesystems cannot link, so fall back to checking and renaming.
+		if _, err := os.Lstat(p); err == nil {
+			return &fs.PathError{Op: "create", Path: p, Err: fs.ErrExist}
+		}
+		if err := os.Rename(tmp, p); err != nil {
+			return err
+		}
+	}
+	return syncDir(filepath.Dir(p))
+}
+
+// writeFile writes b to p on disk, overwriting any file already there.
+// The data is written to a temporary file which then replaces p, so a crash never leaves
+// a partly written file behind.
+func writeFile(p string, b []byte) error {
+	tmp, err := writeTemp(p, b)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(p))
+}
+
+// writeTemp writes b to a new synced file next to p, and returns the temporary file's name.
+func writeTemp(p string, b []byte) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(p), "."+filepath.Base(p)+".tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// syncDir makes a rename in dir durable. Not every system can sync a folder, so failing to is not an error.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	d.Sync()
+	return d.Close()
+}
@@ -0,0 +1,190 @@
+package filesystem_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ifo/dev.journal/filesystem"
+	"github.com/ifo/dev.journal/filesystem/fstest"
+)
+
+// testFS returns each filesystem.FS implementation, empty.
+func testFS(t *testing.T) map[string]filesystem.FS {
+	root, err := ioutil.TempDir("", "devj-fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+	return map[string]filesystem.FS{"os": filesystem.OS(root), "memory": fstest.NewMemFS()}
+}
+
+func TestWriteFiles(t *testing.T) {
+	const name = "2019-01-31.md"
+	for kind, fsys := range testFS(t) {
+		steps := []struct {
+			Name   string
+			Write  func() error
+			Err    string
+			Want   string
+			Backup string
+		}{
+			{Name: "new file", Write: func() error { return filesystem.SafeWriteFile(fsys, name, []byte("one")) }, Want: "one"},
+			{Name: "no overwrite", Write: func() error { return filesystem.SafeWriteFile(fsys, name, []byte("two")) },
+				Err: "file at path: " + name + " already exists", Want: "one"},
+			{Name: "replace", Write: func() error { return fsys.WriteFile(name, []byte("two")) }, Want: "two"},
+			{Name: "overwrite", Write: func() error { return filesystem.OverwriteFile(fsys, name, []byte("three"), "backup") },
+				Want: "three", Backup: "two"},
+		}
+
+		for _, step := range steps {
+			err := step.Write()
+			if err != nil && err.Error() != step.Err || err == nil && step.Err != "" {
+				t.Errorf(testFail, err, step.Err, kind+" "+step.Name)
+			}
+			if got, _ := fsys.ReadFile(name); string(got) != step.Want {
+				t.Errorf(testFail, string(got), step.Want, kind+" "+step.Name)
+			}
+			if step.Backup != "" {
+				if got, _ := fsys.ReadFile("backup/" + name); string(got) != step.Backup {
+					t.Errorf(testFail, string(got), step.Backup, kind+" "+step.Name)
+				}
+			}
+		}
+
+		// Nothing but the file and its backup is left behind.
+		names, _ := filesystem.ListFiles(fsys, ".")
+		if strings.Join(names, " ") != "2019-01-31.md backup" {
+			t.Errorf(testFail, names, []string{"2019-01-31.md", "backup"}, kind)
+		}
+	}
+}
+
+func TestFS(t *testing.T) {
+	for kind, fsys := range testFS(t) {
+		if err := fsys.WriteFile("2019-01-31/2019-01-31.md", nil); err == nil {
+			t.Errorf("%s: wrote into a missing folder", kind)
+		}
+		if err := fsys.MkdirAll("2019-01-31"); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.WriteFile("2019-01-31/2019-01-31.md", []byte("entry")); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.Remove("2019-01-31"); err == nil {
+			t.Errorf("%s: removed a folder that is not empty", kind)
+		}
+		if err := fsys.Rename("2019-01-31", "2019-02-01"); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := fsys.ReadFile("2019-02-01/2019-01-31.md"); string(got) != "entry" {
+			t.Errorf(testFail, err, "entry", kind+" rename")
+		}
+		if err := fsys.WriteFile("../outside.md", nil); err == nil {
+			t.Errorf("%s: wrote outside the journal", kind)
+		}
+		if err := fsys.Remove("2019-02-01/2019-01-31.md"); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.Remove("2019-02-01"); err != nil {
+			t.Fatal(err)
+		}
+		if names, _ := filesystem.ListFiles(fsys, "."); len(names) != 0 {
+			t.Errorf(testFail, names, nil, kind+" remove")
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	for kind, fsys := range testFS(t) {
+		unlock, err := filesystem.Lock(fsys, ".", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := filesystem.Lock(fsys, ".", 100*time.Millisecond); err == nil || !strings.HasPrefix(err.Error(), "journal is locked by process") {
+			t.Errorf(testFail, err, "journal is locked by process ...", kind+" locked")
+		}
+		if err := unlock(); err != nil {
+			t.Fatal(err)
+		}
+
+		// A lock left by a process that is gone is taken over.
+		if err := fsys.WriteFile(filesystem.LockFile, []byte("999999999\n")); err != nil {
+			t.Fatal(err)
+		}
+		unlock, err = filesystem.Lock(fsys, ".", 0)
+		if err != nil {
+			t.Errorf(testFail, err, nil, kind+" stale")
+		} else {
+			unlock()
+		}
+
+		// A takeover left by a process that stopped midway is in the way until it is removed.
+		fsys.WriteFile(filesystem.LockFile, []byte("999999999\n"))
+		fsys.WriteFile(filesystem.LockFile+filesystem.TakeoverSuffix, []byte("999999998\n"))
+		if _, err := filesystem.Lock(fsys, ".", 0); err == nil || !strings.Contains(err.Error(), "in the way") {
+			t.Errorf(testFail, err, "... is in the way of taking it over ...", kind+" takeover")
+		}
+		fsys.Remove(filesystem.LockFile + filesystem.TakeoverSuffix)
+		fsys.Remove(filesystem.LockFile)
+	}
+}
+
+// slowLockFS returns the lock file slower each time it is read, so that processes taking over
+// a stale lock read it together, but act on it one after another.
+type slowLockFS struct {
+	filesystem.FS
+	reads *int32
+}
+
+func (s slowLockFS) ReadFile(name string) ([]byte, error) {
+	b, err := s.FS.ReadFile(name)
+	if name == filesystem.LockFile {
+		n := atomic.AddInt32(s.reads, 1)
+		time.Sleep(time.Duration(n%8) * 2 * time.Millisecond)
+	}
+	return b, err
+}
+
+func TestLock_Concurrent(t *testing.T) {
+	for kind, fsys := range testFS(t) {
+		fsys = slowLockFS{FS: fsys, reads: new(int32)}
+		// Everyone waiting takes over the stale lock, or waits for whoever did, so only one holds it at a time.
+		if err := fsys.WriteFile(filesystem.LockFile, []byte("999999999\n")); err != nil {
+			t.Fatal(err)
+		}
+		var mu sync.Mutex
+		holders, most := 0, 0
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock, err := filesystem.Lock(fsys, ".", 10*time.Second)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				holders++
+				if holders > most {
+					most = holders
+				}
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				holders--
+				mu.Unlock()
+				unlock()
+			}()
+		}
+		wg.Wait()
+		if most != 1 {
+			t.Errorf(testFail, most, 1, kind+" holders at once")
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+// Doc identifies a single section of a journal entry, which is what the index searches.
+type Doc struct {
+	Name    entry.EntryName
+	Section int
+}
+
+// Result is a section matching a query, with the line that best shows why.
+type Result struct {
+	Doc
+	Title string
+	// Line is the index of Snippet among the lines of the section body.
+	Line    int
+	Snippet string
+	// Highlights are the byte ranges of Snippet matching the query.
+	Highlights [][2]int
+}
+
+// Highlight returns the snippet with every highlighted range wrapped in start and end.
+func (r Result) Highlight(start, end string) string {
+	out, last := "", 0
+	for _, h := range r.Highlights {
+		out += r.Snippet[last:h[0]] + start + r.Snippet[h[0]:h[1]] + end
+		last = h[1]
+	}
+	return out + r.Snippet[last:]
+}
+
+// Index is an inverted index over the sections of a journal.
+// Words are case folded and stemmed, so a query for "deploy" also finds "Deployed".
+// It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[Doc]*document
+	postings map[string]map[Doc][]int
+	hashes   map[entry.EntryName]string
+}
+
+type document struct {
+	title  string
+	lines  []string
+	tokens []token
+	tags   map[string]struct{}
+}
+
+// token is a single indexed word, found at line[start:end] of its document.
+type token struct {
+	term       string
+	line       int
+	start, end int
+}
+
+// New creates an empty index.
+func New() *Index {
+	return &Index{
+		docs:     map[Doc]*document{},
+		postings: map[string]map[Doc][]int{},
+		hashes:   map[entry.EntryName]string{},
+	}
+}
+
+// Build creates an index of every entry in j.
+func Build(j *entry.Journal) *Index {
+	ix := New()
+	ix.Update(j)
+	return ix
+}
+
+// Add indexes the entry e under name, replacing whatever was indexed under name before.
+func (ix *Index) Add(name entry.EntryName, e entry.Entry) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.add(name, e)
+}
+
+// Remove takes the entry called name out of the index.
+func (ix *Index) Remove(name entry.EntryName) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(name)
+}
+
+// Update brings the index in line with j, only indexing again the entries that were added or changed,
+// and removing those that are no longer in j. It returns the number of entries it changed.
+func (ix *Index) Update(j *entry.Journal) int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	changed := 0
+	for name := range ix.hashes {
+		if _, ok := j.Entries[name]; !ok {
+			ix.remove(name)
+			changed++
+		}
+	}
+	for name, e := range j.Entries {
+		if hash, ok := ix.hashes[name]; ok && hash == e.Hash() {
+			continue
+		}
+		ix.add(name, e)
+		changed++
+	}
+	return changed
+}
+
+// Search finds every section matching query, newest entries first.
+// See Parse for the query syntax.
+func (ix *Index) Search(query string) ([]Result, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var results []Result
+	for doc := range q.root.eval(ix) {
+		results = append(results, ix.result(doc, q.terms))
+	}
+	sort.Slice(results, func(i, k int) bool {
+		if results[i].Name != results[k].Name {
+			return results[i].Name > results[k].Name
+		}
+		return results[i].Section < results[k].Section
+	})
+	return results, nil
+}
+
+func (ix *Index) add(name entry.EntryName, e entry.Entry) {
+	ix.remove(name)
+	ix.hashes[name] = e.Hash()
+	for i, s := range e.Sections {
+		doc := Doc{Name: name, Section: i}
+		d := &document{title: s.Title, lines: strings.Split(s.Body, "\n"), tags: map[string]struct{}{}}
+		for n, l := range d.lines {
+			d.tokens = append(d.tokens, tokenize(l, n)...)
+		}
+		for _, tag := range entry.FindTags(s.Body) {
+			d.tags[tag] = struct{}{}
+		}
+		for pos, t := range d.tokens {
+			if ix.postings[t.term] == nil {
+				ix.postings[t.term] = map[Doc][]int{}
+			}
+			ix.postings[t.term][doc] = append(ix.postings[t.term][doc], pos)
+		}
+		ix.docs[doc] = d
+	}
+}
+
+func (ix *Index) remove(name entry.EntryName) {
+	if _, ok := ix.hashes[name]; !ok {
+		return
+	}
+	delete(ix.hashes, name)
+	for doc, d := range ix.docs {
+		if doc.Name != name {
+			continue
+		}
+		for _, t := range d.tokens {
+			delete(ix.postings[t.term], doc)
+			if len(ix.postings[t.term]) == 0 {
+				delete(ix.postings, t.term)
+			}
+		}
+		delete(ix.docs, doc)
+	}
+}
+
+// result picks the first line of doc holding one of terms as its snippet.
+func (ix *Index) result(doc Doc, terms map[string]struct{}) Result {
+	d := ix.docs[doc]
+	r := Result{Doc: doc, Title: d.title, Line: -1}
+	for _, t := range d.tokens {
+		if _, ok := terms[t.term]; !ok || r.Line != -1 && t.line != r.Line {
+			continue
+		}
+		r.Line = t.line
+		r.Highlights = append(r.Highlights, [2]int{t.start, t.end})
+	}
+	if r.Line == -1 {
+		r.Line = 0
+		for r.Line < len(d.lines)-1 && strings.TrimSpace(d.lines[r.Line]) == "" {
+			r.Line++
+		}
+	}
+	r.Snippet = d.lines[r.Line]
+	return r
+}
+
+// tokenize splits a line into case folded and stemmed words.
+func tokenize(line string, n int) []token {
+	var tokens []token
+	start := -1
+	for i, r := range line + " " {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start == -1 {
+			start = i
+		} else if !inWord && start != -1 {
+			tokens = append(tokens, token{term: Stem(strings.ToLower(line[start:i])), line: n, start: start, end: i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// terms tokenizes text for searching.
+func terms(text string) []string {
+	var out []string
+	for _, t := range tokenize(text, 0) {
+		out = append(out, t.term)
+	}
+	return out
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+func testJournal() *entry.Journal {
+	j := entry.NewJournal()
+	j.Entries["2019-01-01"] = entry.Entry{Sections: []entry.Section{
+		{Title: "Do", Body: "- Deployed the API\n- paged twice #oncall"},
+		{Title: "Learn", Body: "Rolling deploys need health checks."},
+	}}
+	j.Entries["2019-01-02"] = entry.Entry{Sections: []entry.Section{
+		{Title: "Do", Body: "- wrote docs\n- deploying the database"},
+		{Title: "Learn", Body: "Health checks can lie. #OnCall"},
+	}}
+	j.Entries["2019-01-03"] = entry.Entry{Sections: []entry.Section{
+		{Title: "Do", Body: "nothing"},
+	}}
+	return j
+}
+
+func docs(results []Result) []Doc {
+	var out []Doc
+	for _, r := range results {
+		out = append(out, r.Doc)
+	}
+	return out
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := Build(testJournal())
+
+	tests := map[string]struct {
+		Query string
+		Docs  []Doc
+		Err   string
+	}{
+		"stemmed word": {
+			Query: "DEPLOY",
+			Docs:  []Doc{{"2019-01-02", 0}, {"2019-01-01", 0}, {"2019-01-01", 1}}},
+		"implicit and": {
+			Query: "deploy health",
+			Docs:  []Doc{{"2019-01-01", 1}}},
+		"phrase": {
+			Query: `"health checks can"`,
+			Docs:  []Doc{{"2019-01-02", 1}}},
+		"section": {
+			Query: "section:learn health",
+			Docs:  []Doc{{"2019-01-02", 1}, {"2019-01-01", 1}}},
+		"tag": {
+			Query: "tag:oncall",
+			Docs:  []Doc{{"2019-01-02", 1}, {"2019-01-01", 0}}},
+		"dates": {
+			Query: "after:2019-01-01 before:2019-01-03 section:Do",
+			Docs:  []Doc{{"2019-01-02", 0}}},
+		"or and not": {
+			Query: "(docs OR nothing) NOT -wrote",
+			Docs:  []Doc{{"2019-01-02", 0}}},
+		"negation": {
+			Query: "section:do -deploy",
+			Docs:  []Doc{{"2019-01-03", 0}}},
+		"no match": {Query: "kubernetes", Docs: nil},
+		"bad date": {Query: "before:2019-13-01", Err: `invalid date "2019-13-01", there is no month 13`},
+		"unclosed": {Query: "(docs OR", Err: "missing search term"},
+		"empty":    {Query: " ", Err: "empty query"},
+	}
+
+	for id, test := range tests {
+		results, err := ix.Search(test.Query)
+		if err != nil && err.Error() != test.Err || err == nil && test.Err != "" {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, err, test.Err, id)
+			continue
+		}
+		if d := docs(results); !reflect.DeepEqual(d, test.Docs) {
+			t.Errorf(`Actual: "%v" Expected: "%v" Case: %q`, d, test.Docs, id)
+		}
+	}
+}
+
+func TestIndex_SearchSnippets(t *testing.T) {
+	ix := Build(testJournal())
+
+	results, err := ix.Search("database OR wrote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results[0]
+	if r.Line != 0 || r.Snippet != "- wrote docs" || r.Highlight("[", "]") != "- [wrote] docs" {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	results, err = ix.Search("health -lie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := results[0].Highlight("[", "]"); h != "Rolling deploys need [health] checks." {
+		t.Errorf(`Actual: "%s"`, h)
+	}
+}
+
+func TestIndex_Update(t *testing.T) {
+	j := testJournal()
+	ix := Build(j)
+
+	j.Entries["2019-01-03"] = entry.Entry{Sections: []entry.Section{{Title: "Do", Body: "kubernetes"}}}
+	delete(j.Entries, "2019-01-02")
+	if changed := ix.Update(j); changed != 2 {
+		t.Errorf("got %d changed entries, expected 2", changed)
+	}
+
+	results, _ := ix.Search("kubernetes OR docs")
+	if d := docs(results); !reflect.DeepEqual(d, []Doc{{"2019-01-03", 0}}) {
+		t.Errorf("unexpected results %v", d)
+	}
+	if _, ok := ix.postings["noth"]; ok {
+		t.Errorf("removed words are still indexed")
+	}
+}
@@ -0,0 +1,301 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ifo/dev.journal/entry"
+)
+
+// Query is a parsed search query.
+type Query struct {
+	root node
+	// terms are the words searched for outside of a NOT, which are highlighted in results.
+	terms map[string]struct{}
+}
+
+// Parse parses a search query. Queries are made of:
+//
+//	word             sections containing the word, or any word with the same stem
+//	"some phrase"    sections containing the words next to each other
+//	section:Learn    sections with that title, ignoring case
+//	tag:oncall       sections containing #oncall
+//	before:DATE      sections of entries written before DATE, as YYYY-MM-DD
+//	after:DATE       sections of entries written after DATE
+//
+// Terms next to each other must all match. They can also be combined with AND, OR and NOT,
+// negated with a leading "-", and grouped with parentheses.
+func Parse(query string) (*Query, error) {
+	p := &parser{tokens: lex(query), terms: map[string]struct{}{}}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in query", p.tokens[p.pos])
+	}
+	return &Query{root: root, terms: p.terms}, nil
+}
+
+// lex splits a query into parentheses, quoted phrases and words.
+// A field's quoted value, as in section:"Things Learned", stays part of its word.
+func lex(query string) []string {
+	var tokens []string
+	cur := ""
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			cur += string(r)
+			inQuote = !inQuote
+		case inQuote:
+			cur += string(r)
+		case r == '(' || r == ')':
+			if cur != "" {
+				tokens = append(tokens, cur)
+				cur = ""
+			}
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			if cur != "" {
+				tokens = append(tokens, cur)
+				cur = ""
+			}
+		default:
+			cur += string(r)
+		}
+	}
+	if cur != "" {
+		tokens = append(tokens, cur)
+	}
+	return tokens
+}
+
+type parser struct {
+	tokens  []string
+	pos     int
+	negated int
+	terms   map[string]struct{}
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) parseOr() (node, error) {
+	n, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := orNode{n}
+	for p.peek() == "OR" {
+		p.pos++
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, n)
+	}
+	if len(or) == 1 {
+		return n, nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var and andNode
+	for {
+		switch p.peek() {
+		case "", ")", "OR":
+			if len(and) == 0 {
+				return nil, fmt.Errorf("missing search term")
+			}
+			if len(and) == 1 {
+				return and[0], nil
+			}
+			return and, nil
+		case "AND":
+			p.pos++
+		}
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, n)
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	tok := p.peek()
+	if tok == "NOT" || strings.HasPrefix(tok, "-") && len(tok) > 1 {
+		if tok == "NOT" {
+			p.pos++
+		} else {
+			p.tokens[p.pos] = tok[1:]
+		}
+		p.negated++
+		n, err := p.parseNot()
+		p.negated--
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.parseAtom()
+}
+
+func (p *parser) parseAtom() (node, error) {
+	tok := p.peek()
+	p.pos++
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("missing search term")
+	case "(":
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return n, nil
+	case ")", "AND", "OR":
+		return nil, fmt.Errorf("unexpected %q in query", tok)
+	}
+
+	if i := strings.Index(tok, ":"); i > 0 {
+		field, value := strings.ToLower(tok[:i]), strings.Trim(tok[i+1:], `"`)
+		switch field {
+		case "section":
+			return sectionNode(strings.ToLower(value)), nil
+		case "tag":
+			return tagNode(strings.ToLower(strings.TrimPrefix(value, "#"))), nil
+		case "before", "after":
+			d, err := entry.ParseDate(value)
+			if err != nil {
+				return nil, err
+			}
+			return dateNode{before: field == "before", date: d}, nil
+		}
+	}
+
+	phrase := terms(strings.Trim(tok, `"`))
+	if len(phrase) == 0 {
+		return nil, fmt.Errorf("%q has nothing to search for", tok)
+	}
+	if p.negated == 0 {
+		for _, t := range phrase {
+			p.terms[t] = struct{}{}
+		}
+	}
+	return phraseNode(phrase), nil
+}
+
+type docSet map[Doc]struct{}
+
+type node interface {
+	eval(ix *Index) docSet
+}
+
+type (
+	phraseNode  []string
+	sectionNode string
+	tagNode     string
+	dateNode    struct {
+		before bool
+		date   entry.Date
+	}
+	andNode []node
+	orNode  []node
+	notNode struct{ node }
+)
+
+func (n phraseNode) eval(ix *Index) docSet {
+	out := docSet{}
+	for doc, positions := range ix.postings[n[0]] {
+		tokens := ix.docs[doc].tokens
+	positions:
+		for _, pos := range positions {
+			for i, term := range n[1:] {
+				if pos+i+1 >= len(tokens) || tokens[pos+i+1].term != term {
+					continue positions
+				}
+			}
+			out[doc] = struct{}{}
+			break
+		}
+	}
+	return out
+}
+
+func (n sectionNode) eval(ix *Index) docSet {
+	return ix.filter(func(doc Doc, d *document) bool { return strings.ToLower(d.title) == string(n) })
+}
+
+func (n tagNode) eval(ix *Index) docSet {
+	return ix.filter(func(doc Doc, d *document) bool {
+		_, ok := d.tags[string(n)]
+		return ok
+	})
+}
+
+func (n dateNode) eval(ix *Index) docSet {
+	return ix.filter(func(doc Doc, d *document) bool {
+		date, err := doc.Name.Date()
+		if err != nil {
+			return false
+		}
+		if n.before {
+			return date.Before(n.date)
+		}
+		return date.After(n.date)
+	})
+}
+
+func (n andNode) eval(ix *Index) docSet {
+	out := n[0].eval(ix)
+	for _, other := range n[1:] {
+		set := other.eval(ix)
+		for doc := range out {
+			if _, ok := set[doc]; !ok {
+				delete(out, doc)
+			}
+		}
+	}
+	return out
+}
+
+func (n orNode) eval(ix *Index) docSet {
+	out := docSet{}
+	for _, other := range n {
+		for doc := range other.eval(ix) {
+			out[doc] = struct{}{}
+		}
+	}
+	return out
+}
+
+func (n notNode) eval(ix *Index) docSet {
+	set := n.node.eval(ix)
+	return ix.filter(func(doc Doc, d *document) bool {
+		_, ok := set[doc]
+		return !ok
+	})
+}
+
+func (ix *Index) filter(keep func(Doc, *document) bool) docSet {
+	out := docSet{}
+	for doc, d := range ix.docs {
+		if keep(doc, d) {
+			out[doc] = struct{}{}
+		}
+	}
+	return out
+}
@@ -0,0 +1,20 @@
+package search
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses": "caress", "ponies": "poni", "cats": "cat", "feed": "feed", "agreed": "agre",
+		"plastered": "plaster", "motoring": "motor", "sing": "sing", "conflated": "conflat",
+		"troubled": "troubl", "sized": "size", "hopping": "hop", "falling": "fall", "filing": "file",
+		"happy": "happi", "relational": "relat", "conditional": "condit", "generalization": "gener",
+		"electrical": "electr", "adjustment": "adjust", "adoption": "adopt", "controll": "control",
+		"deployed": "deploi", "deploying": "deploi", "deploys": "deploi", "go": "go", "k8s": "k8s",
+	}
+
+	for word, stem := range tests {
+		if s := Stem(word); s != stem {
+			t.Errorf(`Actual: "%s" Expected: "%s" Case: %q`, s, stem, word)
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -21,10 +27,17 @@ import (
 
 const journalDir = "journals"
 
-// Functions overwriteable for testing purposes.
+// uploadsFile, in each user's journal folder, has a line for every upload: when it was received,
+// the time zone the client's journal is in, and the entries it held.
+const uploadsFile = "uploads.log"
+
+var offsetRegex = regexp.MustCompile(`^[+-]\d{2}:\d{2}$`)
+
+// Overwriteable for testing purposes.
 var (
-	folderCreator = filesystem.EnsureFolderExists
-	fileWriter    = filesystem.SafeWriteFile
+	// store holds the journals of every user, under journalDir.
+	store    filesystem.FS = filesystem.OS(".")
+	lockWait               = filesystem.LockWait
 )
 
 func main() {
@@ -39,7 +52,7 @@ func main() {
 	}
 
 	// Ensure the file database directory exists.
-	if err := filesystem.EnsureFolderExists(journalDir); err != nil {
+	if err := filesystem.EnsureFolderExists(store, journalDir); err != nil {
 		log.Fatalf("error making directory: %v", err)
 	}
 
@@ -85,7 +98,7 @@ func Setup() (ServerConfig, error) {
 // SetupLogger ensures a logging directory and file exists, and then sets up Logrus.
 func SetupLogger() (*logrus.Logger, error) {
 	// Ensure logging directory exists.
-	if err := filesystem.EnsureFolderExists("logs"); err != nil {
+	if err := os.MkdirAll("logs", os.ModePerm); err != nil {
 		return nil, fmt.Errorf("error making directory: %v", err)
 	}
 	f, err := os.OpenFile("logs/server.logs", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
@@ -153,41 +166,161 @@ func Auth(next http.Handler) http.Handler {
 func postJournalHandler(w http.ResponseWriter, r *http.Request) {
 	userDir := r.Context().Value(userKey).(string)
 	// Ensure userDir exists.
-	if err := folderCreator(filepath.Join(journalDir, userDir)); err != nil {
+	if err := filesystem.EnsureFolderExists(store, path.Join(journalDir, userDir)); err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
+	// Only one upload writes to a journal at a time.
+	unlock, err := filesystem.Lock(store, path.Join(journalDir, userDir), lockWait)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer unlock()
 
 	// Decode the entries.
 	var journal entry.Journal
 	dec := json.NewDecoder(r.Body)
-	err := dec.Decode(&journal)
+	err = dec.Decode(&journal)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		http.Error(w, err.Error(), 400)
 		return
 	}
 	defer r.Body.Close()
+	if !validTimezone(journal.Timezone) {
+		http.Error(w, fmt.Sprintf("unknown timezone %q", journal.Timezone), 400)
+		return
+	}
+	for name, e := range journal.Entries {
+		for fname := range e.PublicFiles {
+			if !validFileName(name, fname) {
+				http.Error(w, fmt.Sprintf("bad file name %q in %s", fname, name), 400)
+				return
+			}
+		}
+	}
 
-	for dir, e := range journal.Entries {
-		newDir := filepath.Join(journalDir, userDir, string(dir))
-		if err := folderCreator(newDir); err != nil {
+	for _, name := range journal.Names() {
+		newDir := path.Join(journalDir, userDir, string(name))
+		revDir := path.Join(newDir, "revisions")
+		if err := filesystem.EnsureFolderExists(store, revDir); err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		// Export the entry.
-		err := fileWriter(filepath.Join(newDir, fmt.Sprintf("%s.md", dir)), []byte(e.Export()))
+		// Store every revision not already stored, numbered after the stored ones.
+		// The client's latest revision is stored again if it went back to an older one, such as A, B, A,
+		// so the latest stored revision is always the entry.
+		stored, latest, err := storedRevisions(revDir)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		// Export any public files.
-		for name, contents := range e.PublicFiles {
-			err = fileWriter(filepath.Join(newDir, name), contents)
+		history := journal.History(name)
+		for i, rev := range history {
+			contents := rev.Entry.Export()
+			_, exists := stored[contents]
+			if i == len(history)-1 && contents != latest.contents {
+				exists = false
+			}
+			if exists {
+				continue
+			}
+			stored[contents] = struct{}{}
+			latest.number, latest.contents = latest.number+1, contents
+			err := filesystem.SafeWriteFile(store, path.Join(revDir, fmt.Sprintf("%d.md", latest.number)), []byte(contents))
 			if err != nil {
 				http.Error(w, err.Error(), 500)
 				return
 			}
 		}
+		// Export the client's latest revision as the entry.
+		err = store.WriteFile(path.Join(newDir, fmt.Sprintf("%s.md", name)), []byte(journal.Entries[name].Export()))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		// Export any public files. They are never replaced, so a file already stored can only be sent again as it is.
+		for fname, contents := range journal.Entries[name].PublicFiles {
+			file := path.Join(newDir, fname)
+			if old, err := store.ReadFile(file); err == nil && bytes.Equal(old, contents) {
+				continue
+			}
+			if err := filesystem.SafeWriteFile(store, file, contents); err != nil {
+				http.Error(w, err.Error(), 409)
+				return
+			}
+		}
+	}
+	if err := recordUpload(path.Join(journalDir, userDir), journal); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
 	}
 	// Empty 200 response.
 }
+
+// validFileName reports whether fname can be stored as a public file of the entry called name:
+// a plain name in the entry's folder, which is neither the entry itself nor its revisions.
+func validFileName(name entry.EntryName, fname string) bool {
+	return fname != "" && fname != "." && fname != ".." && !strings.ContainsAny(fname, `/\`) &&
+		fname != string(name)+".md" && fname != "revisions"
+}
+
+// validTimezone reports whether tz names a time zone or an offset from UTC.
+// Clients from before time zones were sent leave it empty.
+func validTimezone(tz string) bool {
+	if tz == "" || offsetRegex.MatchString(tz) {
+		return true
+	}
+	_, err := time.LoadLocation(tz)
+	return err == nil
+}
+
+// recordUpload adds a line for journal to the uploads file in userDir.
+func recordUpload(userDir string, journal entry.Journal) error {
+	name := path.Join(userDir, uploadsFile)
+	uploads, err := store.ReadFile(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	tz := journal.Timezone
+	if tz == "" {
+		tz = "-"
+	}
+	var names []string
+	for _, n := range journal.Names() {
+		names = append(names, string(n))
+	}
+	line := fmt.Sprintf("%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), tz, strings.Join(names, ","))
+	return store.WriteFile(name, append(uploads, line...))
+}
+
+type storedRevision struct {
+	number   int
+	contents string
+}
+
+// storedRevisions reads the revisions stored in revDir as "<number>.md",
+// returning the set of their contents and the latest of them.
+func storedRevisions(revDir string) (map[string]struct{}, storedRevision, error) {
+	stored := map[string]struct{}{}
+	latest := storedRevision{}
+	names, err := filesystem.ListFiles(store, revDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, latest, err
+	}
+	for _, fname := range names {
+		n, err := strconv.Atoi(strings.TrimSuffix(fname, ".md"))
+		if err != nil || !strings.HasSuffix(fname, ".md") {
+			continue
+		}
+		contents, err := store.ReadFile(path.Join(revDir, fname))
+		if err != nil {
+			return nil, latest, err
+		}
+		stored[string(contents)] = struct{}{}
+		if n > latest.number {
+			latest = storedRevision{number: n, contents: string(contents)}
+		}
+	}
+	return stored, latest, nil
+}
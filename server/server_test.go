@@ -6,9 +6,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/ifo/dev.journal/entry"
+	"github.com/ifo/dev.journal/filesystem"
+	"github.com/ifo/dev.journal/filesystem/fstest"
 )
 
 func TestCreateBaseContext(t *testing.T) {
@@ -85,8 +90,7 @@ func TestPostJournalHandler(t *testing.T) {
 	defer resetFileSystem()
 
 	// Overwrite the functions
-	folderCreator = FakeCreateFolder
-	fileWriter = FakeWriteFile
+	store = fstest.NewMemFS()
 
 	recorder := httptest.NewRecorder()
 	bts, _ := json.Marshal(entry.Journal{Entries: map[entry.EntryName]entry.Entry{"2019-03-19": entry.Default}})
@@ -96,27 +100,230 @@ func TestPostJournalHandler(t *testing.T) {
 
 	postJournalHandler(recorder, request)
 
-	file := fileSystem["journals/user/2019-03-19/2019-03-19.md"]
+	file, _ := store.ReadFile("journals/user/2019-03-19/2019-03-19.md")
 	if string(file) != entry.Default.Export() {
 		t.Errorf("Got %s,\n\nexpected %s\n", string(file), entry.Default.Export())
 	}
 }
 
-var fileSystem = map[string][]byte{}
+func TestPostJournalHandler_BadName(t *testing.T) {
+	defer resetFileSystem()
 
-func resetFileSystem() {
-	fileSystem = map[string][]byte{}
+	store = fstest.NewMemFS()
+
+	recorder := httptest.NewRecorder()
+	bts, _ := json.Marshal(entry.Journal{Entries: map[entry.EntryName]entry.Entry{"backup-2019-03-19": entry.Default}})
+	request, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(bts))
+	request = request.WithContext(context.WithValue(request.Context(), userKey, "user"))
+
+	postJournalHandler(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("got %d status, expected %d", recorder.Code, http.StatusBadRequest)
+	}
+	for _, path := range store.(*fstest.MemFS).Names() {
+		t.Errorf("unexpected file %s", path)
+	}
 }
 
-func FakeCreateFolder(folder string) error {
-	return nil
+func TestPostJournalHandler_Locked(t *testing.T) {
+	defer resetFileSystem()
+
+	mem := fstest.NewMemFS()
+	store, lockWait = mem, 0
+	// This process is running, so its lock is not stale.
+	mem.MkdirAll("journals/user")
+	mem.WriteFile("journals/user/"+filesystem.LockFile, []byte(strconv.Itoa(os.Getpid())))
+
+	recorder := httptest.NewRecorder()
+	bts, _ := json.Marshal(entry.Journal{Entries: map[entry.EntryName]entry.Entry{"2019-03-19": entry.Default}})
+	request, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(bts))
+	request = request.WithContext(context.WithValue(request.Context(), userKey, "user"))
+
+	postJournalHandler(recorder, request)
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d status, expected %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+	if names := mem.Names(); len(names) != 1 {
+		t.Errorf("unexpected files %v", names)
+	}
 }
 
-func FakeWriteFile(path string, b []byte) error {
-	fileSystem[path] = b
-	return nil
+func TestPostJournalHandler_Revisions(t *testing.T) {
+	defer resetFileSystem()
+
+	store = fstest.NewMemFS()
+
+	changed := entry.Entry{Name: "2019-03-19", Sections: []entry.Section{{Title: "Do", Body: "more"}}}
+	first := entry.NewJournal()
+	first.Add(entry.Entry{Name: "2019-03-19", Sections: entry.Default.Sections})
+	second := entry.NewJournal()
+	second.Add(entry.Entry{Name: "2019-03-19", Sections: entry.Default.Sections})
+	second.Add(changed)
+
+	// Uploading the second journal only stores its new revision, and uploading it again stores nothing.
+	for _, jrn := range []*entry.Journal{first, second, second} {
+		bts, _ := json.Marshal(jrn)
+		request, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(bts))
+		request = request.WithContext(context.WithValue(request.Context(), userKey, "user"))
+		recorder := httptest.NewRecorder()
+		postJournalHandler(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("got %d status: %s", recorder.Code, recorder.Body)
+		}
+	}
+
+	expected := map[string]string{
+		"journals/user/2019-03-19/2019-03-19.md":  changed.Export(),
+		"journals/user/2019-03-19/revisions/1.md": entry.Default.Export(),
+		"journals/user/2019-03-19/revisions/2.md": changed.Export(),
+	}
+	// The uploads file is the only other one.
+	if names := store.(*fstest.MemFS).Names(); len(names) != len(expected)+1 {
+		t.Errorf("got files %v, expected %d", names, len(expected))
+	}
+	for path, contents := range expected {
+		if file, _ := store.ReadFile(path); string(file) != contents {
+			t.Errorf("Got %q at %s, expected %q", file, path, contents)
+		}
+	}
+}
+
+func TestPostJournalHandler_RevertedRevision(t *testing.T) {
+	defer resetFileSystem()
+
+	store = fstest.NewMemFS()
+
+	a := entry.Entry{Name: "2019-03-19", Sections: entry.Default.Sections}
+	b := entry.Entry{Name: "2019-03-19", Sections: []entry.Section{{Title: "Do", Body: "more"}}}
+	first := entry.NewJournal()
+	first.Add(a)
+	first.Add(b)
+	// The entry went back to A, which is stored already, but not as the latest revision.
+	reverted := entry.NewJournal()
+	reverted.Add(a)
+	reverted.Add(b)
+	reverted.Add(a)
+
+	for _, jrn := range []*entry.Journal{first, reverted, reverted} {
+		bts, _ := json.Marshal(jrn)
+		request, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(bts))
+		request = request.WithContext(context.WithValue(request.Context(), userKey, "user"))
+		recorder := httptest.NewRecorder()
+		postJournalHandler(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("got %d status: %s", recorder.Code, recorder.Body)
+		}
+	}
+
+	expected := map[string]string{
+		"journals/user/2019-03-19/2019-03-19.md":  a.Export(),
+		"journals/user/2019-03-19/revisions/1.md": a.Export(),
+		"journals/user/2019-03-19/revisions/2.md": b.Export(),
+		"journals/user/2019-03-19/revisions/3.md": a.Export(),
+	}
+	if names := store.(*fstest.MemFS).Names(); len(names) != len(expected)+1 {
+		t.Errorf("got files %v, expected %d", names, len(expected))
+	}
+	for path, contents := range expected {
+		if file, _ := store.ReadFile(path); string(file) != contents {
+			t.Errorf("Got %q at %s, expected %q", file, path, contents)
+		}
+	}
+}
+
+func TestPostJournalHandler_Timezone(t *testing.T) {
+	defer resetFileSystem()
+
+	store = fstest.NewMemFS()
+
+	tests := []struct {
+		Timezone   string
+		StatusCode int
+		Recorded   string
+	}{
+		{Timezone: "Europe/Berlin", StatusCode: http.StatusOK, Recorded: "Europe/Berlin"},
+		{Timezone: "-05:00", StatusCode: http.StatusOK, Recorded: "-05:00"},
+		{Timezone: "", StatusCode: http.StatusOK, Recorded: "-"},
+		{Timezone: "Mars/Olympus", StatusCode: http.StatusBadRequest},
+	}
+
+	var expected []string
+	for _, test := range tests {
+		jrn := entry.Journal{Entries: map[entry.EntryName]entry.Entry{"2019-03-19": entry.Default}, Timezone: test.Timezone}
+		bts, _ := json.Marshal(jrn)
+		request, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(bts))
+		request = request.WithContext(context.WithValue(request.Context(), userKey, "user"))
+		recorder := httptest.NewRecorder()
+		postJournalHandler(recorder, request)
+		if recorder.Code != test.StatusCode {
+			t.Errorf("got %d status for %q, expected %d", recorder.Code, test.Timezone, test.StatusCode)
+		}
+		if test.Recorded != "" {
+			expected = append(expected, test.Recorded+"\t2019-03-19")
+		}
+	}
+
+	uploads, _ := store.ReadFile("journals/user/" + uploadsFile)
+	lines := strings.Split(strings.TrimSuffix(string(uploads), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("got uploads %q, expected %d", uploads, len(expected))
+	}
+	for i, line := range lines {
+		// Each line starts with the time it was received.
+		if fields := strings.SplitN(line, "\t", 2); len(fields) != 2 || fields[1] != expected[i] {
+			t.Errorf("got upload %q, expected it to end in %q", line, expected[i])
+		}
+	}
+}
+
+func resetFileSystem() {
+	store, lockWait = filesystem.OS("."), filesystem.LockWait
 }
 
 func GetEmptyHandler() http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 }
+
+func TestPostJournalHandler_PublicFiles(t *testing.T) {
+	defer resetFileSystem()
+
+	store = fstest.NewMemFS()
+	post := func(files map[string][]byte) int {
+		e := entry.Default
+		e.PublicFiles = files
+		bts, _ := json.Marshal(entry.Journal{Entries: map[entry.EntryName]entry.Entry{"2019-03-19": e}})
+		request, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(bts))
+		request = request.WithContext(context.WithValue(request.Context(), userKey, "user"))
+		recorder := httptest.NewRecorder()
+		postJournalHandler(recorder, request)
+		return recorder.Code
+	}
+	if code := post(map[string][]byte{"notes.txt": []byte("notes")}); code != http.StatusOK {
+		t.Fatalf("got %d status, expected %d", code, http.StatusOK)
+	}
+
+	tests := map[string]struct {
+		Files map[string][]byte
+		Code  int
+	}{
+		"sent again":        {Files: map[string][]byte{"notes.txt": []byte("notes")}, Code: http.StatusOK},
+		"changed":           {Files: map[string][]byte{"notes.txt": []byte("other")}, Code: http.StatusConflict},
+		"another entry":     {Files: map[string][]byte{"../2019-03-18/2019-03-18.md": []byte("x")}, Code: http.StatusBadRequest},
+		"a revision":        {Files: map[string][]byte{"revisions/1.md": []byte("x")}, Code: http.StatusBadRequest},
+		"the entry":         {Files: map[string][]byte{"2019-03-19.md": []byte("x")}, Code: http.StatusBadRequest},
+		"the parent folder": {Files: map[string][]byte{"..": []byte("x")}, Code: http.StatusBadRequest},
+	}
+	for name, test := range tests {
+		if code := post(test.Files); code != test.Code {
+			t.Errorf("Actual: %d Expected: %d Case: %q", code, test.Code, name)
+		}
+	}
+	if file, _ := store.ReadFile("journals/user/2019-03-19/notes.txt"); string(file) != "notes" {
+		t.Errorf("Got %s, expected notes", file)
+	}
+	if file, _ := store.ReadFile("journals/user/2019-03-19/revisions/1.md"); string(file) != entry.Default.Export() {
+		t.Errorf("revision 1 was overwritten with %s", file)
+	}
+}